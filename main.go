@@ -4,14 +4,34 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
+	sourcepb "github.com/numaproj/numaflow-go/pkg/apis/proto/source/v1"
+	"github.com/numaproj/numaflow-go/pkg/info"
+	"github.com/numaproj/numaflow-go/pkg/shared"
 	"github.com/numaproj/numaflow-go/pkg/sourcer"
+	"go.uber.org/zap"
 
 	"github.com/numaproj-contrib/nats-source-go/pkg/config"
+	"github.com/numaproj-contrib/nats-source-go/pkg/health"
+	"github.com/numaproj-contrib/nats-source-go/pkg/metrics"
 	"github.com/numaproj-contrib/nats-source-go/pkg/nats"
 	"github.com/numaproj-contrib/nats-source-go/pkg/utils"
 )
 
+const (
+	// sourceSockAddr and maxMessageSize are the defaults of the sourcer server of numaflow-go.
+	sourceSockAddr = "/var/run/numaflow/source.sock"
+	maxMessageSize = 1024 * 1024 * 64
+)
+
+// drainingSource is a source which can be drained before it is no longer served.
+type drainingSource interface {
+	sourcer.Sourcer
+	Drain()
+}
+
 func main() {
 	logger := utils.NewLogger()
 	// Get the config file path and format from env vars
@@ -25,9 +45,9 @@ func main() {
 	var config *config.Config
 	var err error
 
-	config, err = getConfigFromEnvVars(format)
+	config, err = getConfigFromEnvVars(format, logger)
 	if err != nil {
-		config, err = getConfigFromFile(format)
+		config, err = getConfigFromFile(format, logger)
 		if err != nil {
 			logger.Panic("Failed to parse config file : ", err)
 		} else {
@@ -37,21 +57,86 @@ func main() {
 		logger.Info("Successfully parsed config from env vars")
 	}
 
-	natsSrc, err := nats.New(config)
+	if err = config.Validate(); err != nil {
+		logger.Panic("Invalid config : ", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var opts []nats.Option
+	if config.Metrics != nil {
+		m := metrics.New()
+		opts = append(opts, nats.WithMetrics(m))
+		port := config.Metrics.Port
+		if port == 0 {
+			port = metrics.DefaultPort
+		}
+		go func() {
+			if err := m.Serve(ctx, port, logger); err != nil {
+				logger.Error("Failed to serve metrics : ", err)
+			}
+		}()
+	}
+
+	// The probes are served while connecting, which can be retried for a while, so that the pod is not restarted.
+	checker := &health.Deferred{}
+	if config.Health != nil {
+		port := config.Health.Port
+		if port == 0 {
+			port = health.DefaultPort
+		}
+		go func() {
+			if err := health.Serve(ctx, port, checker, logger); err != nil {
+				logger.Error("Failed to serve health probes : ", err)
+			}
+		}()
+	}
+
+	natsSrc, err := nats.New(config, opts...)
 	if err != nil {
 		logger.Panic("Failed to create nats source : ", err)
 	}
 	defer natsSrc.Close()
+	checker.Set(natsSrc)
 
-	err = sourcer.NewServer(natsSrc).Start(context.Background())
+	err = serve(ctx, natsSrc)
 	if err != nil {
 		logger.Panic("Failed to start source server : ", err)
 	}
 }
 
-func getConfigFromFile(format string) (*config.Config, error) {
+// serve serves the source to Numaflow like the sourcer server of numaflow-go, until SIGINT or SIGTERM.
+// The sourcer server stops serving as soon as it gets the signal, so the source is drained here first,
+// while Numaflow can still read the buffered messages.
+func serve(ctx context.Context, src drainingSource) error {
+	lis, err := shared.PrepareServer(sourceSockAddr, info.ServerInfoFilePath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = lis.Close() }()
+	grpcServer := shared.CreateGRPCServer(maxMessageSize)
+	sourcepb.RegisterSourceServer(grpcServer, &sourcer.Service{Source: src})
+
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- grpcServer.Serve(lis)
+	}()
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("failed to serve the source, %w", err)
+	case <-ctx.Done():
+	}
+	src.Drain()
+	grpcServer.GracefulStop()
+	return nil
+}
+
+func getConfigFromFile(format string, logger *zap.SugaredLogger) (*config.Config, error) {
 	if format == "yaml" {
-		parser := &config.YAMLConfigParser{}
+		parser := &config.YAMLConfigParser{Logger: logger}
 		content, err := os.ReadFile(fmt.Sprintf("%s/nats-config.yaml", utils.ConfigVolumePath))
 		if err != nil {
 			return nil, err
@@ -69,14 +154,14 @@ func getConfigFromFile(format string) (*config.Config, error) {
 	}
 }
 
-func getConfigFromEnvVars(format string) (*config.Config, error) {
+func getConfigFromEnvVars(format string, logger *zap.SugaredLogger) (*config.Config, error) {
 	var c string
 	c, ok := os.LookupEnv("NATS_CONFIG")
 	if !ok {
 		return nil, fmt.Errorf("NATS_CONFIG environment variable is not set")
 	}
 	if format == "yaml" {
-		parser := &config.YAMLConfigParser{}
+		parser := &config.YAMLConfigParser{Logger: logger}
 		return parser.Parse(c)
 	} else if format == "json" {
 		parser := &config.JSONConfigParser{}
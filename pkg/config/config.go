@@ -1,10 +1,17 @@
 package config
 
-import corev1 "k8s.io/api/core/v1"
+import (
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
 
 /* Package config defines the configuration for the NATS user-defined source.
 The configuration includes the URL to connect to NATS cluster, the subject onto which messages are published,
-the queue for queue subscription, the TLS configuration for the NATS client and the authentication information.
+the queue for queue subscription, the TLS configuration for the NATS client, the authentication information
+and optionally the JetStream consumer to read from.
 */
 
 // Config represents the configuration for the NATS client.
@@ -21,6 +28,377 @@ type Config struct {
 	// Auth information
 	// +optional
 	Auth *Auth `json:"auth,omitempty" protobuf:"bytes,5,opt,name=auth"`
+	// JetStream configures the source to read from a JetStream durable pull consumer
+	// instead of a core NATS queue subscription.
+	// +optional
+	JetStream *JetStream `json:"jetstream,omitempty" protobuf:"bytes,6,opt,name=jetstream"`
+	// Keys configures how the keys of the messages are derived.
+	// +optional
+	Keys *Keys `json:"keys,omitempty" protobuf:"bytes,7,opt,name=keys"`
+	// EventTime configures how the event time of the messages is extracted.
+	// Defaults to the time the messages are read when not specified.
+	// +optional
+	EventTime *EventTime `json:"eventTime,omitempty" protobuf:"bytes,8,opt,name=eventTime"`
+	// Subscriptions holds additional subjects to subscribe to, each optionally with its own queue group.
+	// The messages of all the subscriptions are read by the same source. Only used with core NATS.
+	// +optional
+	Subscriptions []Subscription `json:"subscriptions,omitempty" protobuf:"bytes,9,rep,name=subscriptions"`
+	// Drain configures the source to let Numaflow read the buffered messages on shutdown instead of dropping them.
+	// +optional
+	Drain *Drain `json:"drain,omitempty" protobuf:"bytes,10,opt,name=drain"`
+	// Buffer configures the internal buffer holding the received messages until they are read.
+	// +optional
+	Buffer *Buffer `json:"buffer,omitempty" protobuf:"bytes,11,opt,name=buffer"`
+	// Metrics configures the HTTP endpoint serving the Prometheus metrics of the source.
+	// +optional
+	Metrics *Metrics `json:"metrics,omitempty" protobuf:"bytes,12,opt,name=metrics"`
+	// Health configures the HTTP endpoint serving the liveness and readiness probes of the source.
+	// +optional
+	Health *Health `json:"health,omitempty" protobuf:"bytes,13,opt,name=health"`
+	// ConnectRetry configures the source to retry the initial connection to NATS instead of failing right away.
+	// +optional
+	ConnectRetry *ConnectRetry `json:"connectRetry,omitempty" protobuf:"bytes,14,opt,name=connectRetry"`
+	// Connection configures the NATS client connection.
+	// +optional
+	Connection *Connection `json:"connection,omitempty" protobuf:"bytes,15,opt,name=connection"`
+	// Partitions configures the source to read from multiple partitions, each bound to a shard of the subjects.
+	// Subject, Subscriptions and JetStream.FilterSubject are not used with partitions.
+	// +optional
+	Partitions *Partitions `json:"partitions,omitempty" protobuf:"bytes,16,opt,name=partitions"`
+	// DeadLetter republishes the messages which cannot be processed, instead of dropping them.
+	// +optional
+	DeadLetter *DeadLetter `json:"deadLetter,omitempty" protobuf:"bytes,17,opt,name=deadLetter"`
+	// Provision creates or updates the JetStream stream and consumers when the source starts, requires JetStream.
+	// +optional
+	Provision *Provision `json:"provision,omitempty" protobuf:"bytes,18,opt,name=provision"`
+	// KeyValue configures the source to watch a KV bucket instead of subscribing to subjects.
+	// Subject, Subscriptions, JetStream, Partitions, Keys and EventTime are not supported with it.
+	// +optional
+	KeyValue *KeyValue `json:"keyValue,omitempty" protobuf:"bytes,19,opt,name=keyValue"`
+}
+
+// OverflowPolicy determines what happens to a received message when the internal buffer is full.
+type OverflowPolicy string
+
+const (
+	// OverflowBlock waits for room in the buffer, which stops the delivery of the subscription.
+	OverflowBlock OverflowPolicy = "block"
+	// OverflowDropNewest drops the received message.
+	OverflowDropNewest OverflowPolicy = "dropNewest"
+	// OverflowDropOldest drops the oldest buffered message to make room for the received one.
+	OverflowDropOldest OverflowPolicy = "dropOldest"
+)
+
+// Drain defines the graceful shutdown of the source.
+// On SIGTERM, the source stops receiving new messages and keeps being served until the buffered ones are read,
+// before closing.
+type Drain struct {
+	// Timeout is the maximum time to wait for the buffered messages to be read, defaults to 10s.
+	// It has to leave time for the shutdown within the termination grace period of the pod.
+	// +optional
+	Timeout *Duration `json:"timeout,omitempty" protobuf:"bytes,1,opt,name=timeout"`
+}
+
+// Buffer defines the internal buffer of the source.
+type Buffer struct {
+	// Size is the maximum number of buffered messages, defaults to 1000.
+	// +optional
+	Size int `json:"size,omitempty" protobuf:"varint,1,opt,name=size"`
+	// OverflowPolicy is what happens to a received message when the buffer is full, defaults to "block".
+	// It only applies to core NATS, JetStream never pulls more messages than the buffer can hold,
+	// and the KV watch waits for room in the buffer.
+	// +optional
+	OverflowPolicy OverflowPolicy `json:"overflowPolicy,omitempty" protobuf:"bytes,2,opt,name=overflowPolicy"`
+}
+
+// Metrics defines the endpoint serving the Prometheus metrics of the source.
+type Metrics struct {
+	// Port is the port the metrics are served on, under /metrics, defaults to 9090.
+	// +optional
+	Port int `json:"port,omitempty" protobuf:"varint,1,opt,name=port"`
+}
+
+// Health defines the endpoint serving the liveness and readiness probes of the source.
+// The source is ready while it is connected to NATS and its subscriptions are valid,
+// it is live until it has been disconnected from NATS for longer than the grace period.
+type Health struct {
+	// Port is the port the probes are served on, under /livez and /readyz, defaults to 8080.
+	// +optional
+	Port int `json:"port,omitempty" protobuf:"varint,1,opt,name=port"`
+	// DisconnectGracePeriod is how long the source can be disconnected from NATS before it is reported as not live,
+	// defaults to 1m.
+	// +optional
+	DisconnectGracePeriod *Duration `json:"disconnectGracePeriod,omitempty" protobuf:"bytes,2,opt,name=disconnectGracePeriod"`
+}
+
+// ConnectRetry defines how the initial connection to NATS is retried, with an exponential backoff between the attempts.
+type ConnectRetry struct {
+	// MaxAttempts is the maximum number of connection attempts, defaults to 10. A negative value retries forever.
+	// +optional
+	MaxAttempts int `json:"maxAttempts,omitempty" protobuf:"varint,1,opt,name=maxAttempts"`
+	// InitialBackoff is the time to wait after the first failed attempt, defaults to 1s.
+	// It is doubled after every failed attempt.
+	// +optional
+	InitialBackoff *Duration `json:"initialBackoff,omitempty" protobuf:"bytes,2,opt,name=initialBackoff"`
+	// MaxBackoff is the maximum time to wait between two attempts, defaults to 30s.
+	// +optional
+	MaxBackoff *Duration `json:"maxBackoff,omitempty" protobuf:"bytes,3,opt,name=maxBackoff"`
+	// Jitter is the maximum fraction of the backoff randomly added to it, between 0 and 1, defaults to 0.2.
+	// +optional
+	Jitter *float64 `json:"jitter,omitempty" protobuf:"fixed64,4,opt,name=jitter"`
+}
+
+// Connection defines the tuning of the NATS client connection, the client defaults are used for the unset fields
+// unless stated otherwise.
+type Connection struct {
+	// Name is the name of the connection, as reported by the server, e.g. in `nats server report connections`.
+	// +optional
+	Name string `json:"name,omitempty" protobuf:"bytes,1,opt,name=name"`
+	// ReconnectWait is the time to wait between two reconnection attempts, defaults to 3s.
+	// +optional
+	ReconnectWait *Duration `json:"reconnectWait,omitempty" protobuf:"bytes,2,opt,name=reconnectWait"`
+	// MaxReconnects is the maximum number of reconnection attempts before the connection is closed,
+	// defaults to -1 which reconnects forever.
+	// +optional
+	MaxReconnects *int `json:"maxReconnects,omitempty" protobuf:"varint,3,opt,name=maxReconnects"`
+	// PingInterval is the interval between two pings to the server.
+	// +optional
+	PingInterval *Duration `json:"pingInterval,omitempty" protobuf:"bytes,4,opt,name=pingInterval"`
+	// MaxPingsOutstanding is the maximum number of pings without response before the connection is considered lost.
+	// +optional
+	MaxPingsOutstanding int `json:"maxPingsOutstanding,omitempty" protobuf:"varint,5,opt,name=maxPingsOutstanding"`
+	// InboxPrefix is the prefix of the inbox subjects used for the replies, e.g. for the JetStream requests.
+	// +optional
+	InboxPrefix string `json:"inboxPrefix,omitempty" protobuf:"bytes,6,opt,name=inboxPrefix"`
+	// ReconnectBufferSize is the size in bytes of the buffer holding the outgoing data while reconnecting,
+	// -1 disables it.
+	// +optional
+	ReconnectBufferSize int `json:"reconnectBufferSize,omitempty" protobuf:"varint,7,opt,name=reconnectBufferSize"`
+	// PendingMessagesLimit is the maximum number of messages delivered by the server but not processed by
+	// a subscription yet, beyond which the messages are dropped as a slow consumer. -1 means no limit.
+	// +optional
+	PendingMessagesLimit int `json:"pendingMessagesLimit,omitempty" protobuf:"varint,8,opt,name=pendingMessagesLimit"`
+	// PendingBytesLimit is the maximum size in bytes of the messages delivered by the server but not processed by
+	// a subscription yet, beyond which the messages are dropped as a slow consumer. -1 means no limit.
+	// +optional
+	PendingBytesLimit int `json:"pendingBytesLimit,omitempty" protobuf:"varint,9,opt,name=pendingBytesLimit"`
+}
+
+// PartitionPlaceholder is replaced by the partition number in the subject template of the partitions.
+const PartitionPlaceholder = "{partition}"
+
+// Partitions defines the partitions of the source, numbered from 0.
+// Each partition reads the subjects of the template with the placeholder replaced by its number,
+// which are typically produced by a NATS subject mapping, e.g. "orders.*" to "orders.{{partition(3,1)}}.{{wildcard(1)}}".
+// With JetStream, each partition reads from its own durable consumer, named after the consumer and the partition number.
+type Partitions struct {
+	// Count is the number of partitions.
+	Count int `json:"count" protobuf:"varint,1,opt,name=count"`
+	// SubjectTemplate is the subject of the partitions, containing the {partition} placeholder, e.g. "orders.{partition}.>".
+	SubjectTemplate string `json:"subjectTemplate" protobuf:"bytes,2,opt,name=subjectTemplate"`
+}
+
+// Subject returns the subject of the given partition.
+func (p *Partitions) Subject(partition int) string {
+	return strings.ReplaceAll(p.SubjectTemplate, PartitionPlaceholder, strconv.Itoa(partition))
+}
+
+// KeyValue defines the NATS KV bucket to watch. Each put or delete of a key is read as a message keyed by the key,
+// with the value as payload and the time of the revision as event time.
+// The watch starts from the current state of the bucket, the updates made while the source is not running are not read.
+type KeyValue struct {
+	// Bucket is the name of the KV bucket.
+	Bucket string `json:"bucket" protobuf:"bytes,1,opt,name=bucket"`
+	// Keys restricts the watch to the matching keys, with the * and > wildcards, defaults to all the keys.
+	// +optional
+	Keys string `json:"keys,omitempty" protobuf:"bytes,2,opt,name=keys"`
+	// InitialSnapshot reads the current value of each key first, before the updates.
+	// +optional
+	InitialSnapshot bool `json:"initialSnapshot,omitempty" protobuf:"varint,3,opt,name=initialSnapshot"`
+	// Envelope wraps the value in a JSON object holding the key, the operation and the revision of the entry,
+	// so that the deletes can be told apart from the puts downstream.
+	// +optional
+	Envelope bool `json:"envelope,omitempty" protobuf:"varint,4,opt,name=envelope"`
+}
+
+// DeadLetter defines where the messages which cannot be processed are republished: the messages whose event time
+// cannot be extracted with the drop fallback, and the JetStream messages which exceed the maximum number of deliveries.
+// The republished messages keep the payload and the headers of the original message, along with headers describing it.
+type DeadLetter struct {
+	// Subject is the NATS subject the messages are republished to, it must not contain wildcards.
+	Subject string `json:"subject" protobuf:"bytes,1,opt,name=subject"`
+	// JetStream publishes the messages with JetStream, waiting for the stream capturing the subject to store them.
+	// A JetStream message which cannot be stored is negatively acknowledged instead of being terminated.
+	// +optional
+	JetStream bool `json:"jetStream,omitempty" protobuf:"varint,2,opt,name=jetStream"`
+}
+
+// Subscription defines a subject to subscribe to.
+type Subscription struct {
+	// Subject holds the name of the subject onto which messages are published.
+	Subject string `json:"subject" protobuf:"bytes,1,opt,name=subject"`
+	// Queue is used for queue subscription, defaults to the queue of the config.
+	// +optional
+	Queue string `json:"queue,omitempty" protobuf:"bytes,2,opt,name=queue"`
+}
+
+// GetSubscriptions returns all the subscriptions of the config, starting with Subject if it is specified.
+func (c *Config) GetSubscriptions() []Subscription {
+	var subs []Subscription
+	if c.Subject != "" {
+		subs = append(subs, Subscription{Subject: c.Subject, Queue: c.Queue})
+	}
+	for _, s := range c.Subscriptions {
+		if s.Queue == "" {
+			s.Queue = c.Queue
+		}
+		subs = append(subs, s)
+	}
+	return subs
+}
+
+// Keys defines where the keys of the messages are extracted from.
+// When multiple sources are configured, the keys are combined in the order subject tokens, header and JSON path.
+// A source which does not yield a value for a message is skipped.
+type Keys struct {
+	// SubjectTokens are the 1-based positions of the subject tokens used as keys,
+	// e.g. 2 takes "eu" out of the subject "orders.eu.created" matching "orders.*.created".
+	// +optional
+	SubjectTokens []int `json:"subjectTokens,omitempty" protobuf:"varint,1,rep,name=subjectTokens"`
+	// Header is the name of the header whose value is used as a key.
+	// +optional
+	Header string `json:"header,omitempty" protobuf:"bytes,2,opt,name=header"`
+	// JSONPath is the dot separated path of the field in the JSON payload whose value is used as a key,
+	// e.g. "customer.id".
+	// +optional
+	JSONPath string `json:"jsonPath,omitempty" protobuf:"bytes,3,opt,name=jsonPath"`
+}
+
+// DeliverPolicy determines where a newly created JetStream consumer starts delivering messages from.
+type DeliverPolicy string
+
+const (
+	// DeliverAll starts with the earliest message available in the stream.
+	DeliverAll DeliverPolicy = "all"
+	// DeliverLast starts with the last message added to the stream.
+	DeliverLast DeliverPolicy = "last"
+	// DeliverNew only delivers messages published after the consumer is created.
+	DeliverNew DeliverPolicy = "new"
+	// DeliverByStartSequence starts with the message at JetStream.StartSequence.
+	DeliverByStartSequence DeliverPolicy = "byStartSequence"
+	// DeliverByStartTime starts with the first message added to the stream at or after JetStream.StartTime.
+	DeliverByStartTime DeliverPolicy = "byStartTime"
+	// DeliverLastPerSubject starts with the last message of each subject in the stream.
+	DeliverLastPerSubject DeliverPolicy = "lastPerSubject"
+)
+
+// JetStream defines the configuration for reading from a JetStream durable pull consumer.
+type JetStream struct {
+	// Stream is the name of the stream to read from.
+	Stream string `json:"stream" protobuf:"bytes,1,opt,name=stream"`
+	// Consumer is the durable name of the pull consumer, it is created if it does not exist yet.
+	Consumer string `json:"consumer" protobuf:"bytes,2,opt,name=consumer"`
+	// FilterSubject restricts the consumer to the messages published onto the matching subjects.
+	// Defaults to Subject when not specified.
+	// +optional
+	FilterSubject string `json:"filterSubject,omitempty" protobuf:"bytes,3,opt,name=filterSubject"`
+	// DeliverPolicy is the deliver policy used when the consumer is created, defaults to "all".
+	// When set, it must match the policy of an existing consumer or the source fails to start,
+	// replaying from another point requires a new consumer.
+	// +optional
+	DeliverPolicy DeliverPolicy `json:"deliverPolicy,omitempty" protobuf:"bytes,4,opt,name=deliverPolicy"`
+	// AckWait is how long the server waits for a message to be acknowledged before redelivering it, defaults to 30s.
+	// When set, it must match the value of an existing consumer or the source fails to start, unless it is provisioned.
+	// +optional
+	AckWait *Duration `json:"ackWait,omitempty" protobuf:"bytes,5,opt,name=ackWait"`
+	// StartSequence is the stream sequence of the first message delivered, with the "byStartSequence" deliver policy.
+	// +optional
+	StartSequence uint64 `json:"startSequence,omitempty" protobuf:"varint,6,opt,name=startSequence"`
+	// StartTime is the time from which the messages are delivered, with the "byStartTime" deliver policy.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty" protobuf:"bytes,7,opt,name=startTime"`
+	// MaxDeliver is the maximum number of deliveries of a message, defaults to unlimited. A message which is still
+	// not acknowledged after its last delivery is terminated. When set, it must match the value of an existing consumer
+	// or the source fails to start, unless it is provisioned.
+	// +optional
+	MaxDeliver int `json:"maxDeliver,omitempty" protobuf:"varint,8,opt,name=maxDeliver"`
+	// BackOff are the delays before redelivering a message which is not acknowledged, by delivery, the last one being
+	// used for the next deliveries. It requires MaxDeliver to be greater than the number of delays, and must match
+	// the delays of an existing consumer like MaxDeliver.
+	// +optional
+	BackOff []Duration `json:"backOff,omitempty" protobuf:"bytes,9,rep,name=backOff"`
+	// AckDeadline is how long a message can be read without being acknowledged by Numaflow. Once it elapses,
+	// the message is negatively acknowledged to be redelivered after its BackOff delay, or terminated after MaxDeliver
+	// deliveries. It must be shorter than AckWait, or than the shortest BackOff delay. Defaults to relying on AckWait.
+	// +optional
+	AckDeadline *Duration `json:"ackDeadline,omitempty" protobuf:"bytes,10,opt,name=ackDeadline"`
+}
+
+// RetentionPolicy determines when a JetStream stream removes its messages.
+type RetentionPolicy string
+
+const (
+	// RetentionLimits keeps the messages until the limits of the stream are reached.
+	RetentionLimits RetentionPolicy = "limits"
+	// RetentionInterest keeps the messages until all the consumers have acknowledged them.
+	RetentionInterest RetentionPolicy = "interest"
+	// RetentionWorkQueue removes the messages as soon as they are acknowledged.
+	RetentionWorkQueue RetentionPolicy = "workQueue"
+)
+
+// StorageType determines where a JetStream stream stores its messages.
+type StorageType string
+
+const (
+	// StorageFile stores the messages on disk.
+	StorageFile StorageType = "file"
+	// StorageMemory stores the messages in memory.
+	StorageMemory StorageType = "memory"
+)
+
+// Provision describes the JetStream stream and consumers to create, or to update when they already exist.
+// The consumers are configured from the jetstream config, along with the Consumer settings.
+// The settings which the server cannot update, e.g. the retention of a stream or the deliver policy of a consumer,
+// make the source fail to start when they differ from the config.
+type Provision struct {
+	// DryRun only logs the differences between the config and the existing stream and consumers,
+	// without creating or updating them. The consumers are bound as they are, and must exist.
+	// +optional
+	DryRun bool `json:"dryRun,omitempty" protobuf:"varint,1,opt,name=dryRun"`
+	// Stream configures the stream, which is left as is when not specified.
+	// +optional
+	Stream *StreamProvision `json:"stream,omitempty" protobuf:"bytes,2,opt,name=stream"`
+	// Consumer holds the consumer settings which are not part of the jetstream config.
+	// +optional
+	Consumer *ConsumerProvision `json:"consumer,omitempty" protobuf:"bytes,3,opt,name=consumer"`
+}
+
+// StreamProvision defines the settings of a JetStream stream, the ones not specified keep their current value.
+type StreamProvision struct {
+	// Subjects are the subjects captured by the stream.
+	Subjects []string `json:"subjects" protobuf:"bytes,1,rep,name=subjects"`
+	// Retention is the retention policy of the stream, defaults to "limits".
+	// +optional
+	Retention RetentionPolicy `json:"retention,omitempty" protobuf:"bytes,2,opt,name=retention"`
+	// Storage is the storage type of the stream, defaults to "file".
+	// +optional
+	Storage StorageType `json:"storage,omitempty" protobuf:"bytes,3,opt,name=storage"`
+	// Replicas is the number of replicas of the stream in a cluster, defaults to 1.
+	// +optional
+	Replicas int `json:"replicas,omitempty" protobuf:"varint,4,opt,name=replicas"`
+	// MaxAge is the maximum age of the messages in the stream, defaults to unlimited.
+	// +optional
+	MaxAge *Duration `json:"maxAge,omitempty" protobuf:"bytes,5,opt,name=maxAge"`
+}
+
+// ConsumerProvision defines the settings of the JetStream consumers, the ones not specified keep their current value.
+type ConsumerProvision struct {
+	// Replicas is the number of replicas of the consumers in a cluster, defaults to the replicas of the stream.
+	// +optional
+	Replicas int `json:"replicas,omitempty" protobuf:"varint,1,opt,name=replicas"`
+	// MaxAckPending is the maximum number of messages delivered and not acknowledged yet, -1 for unlimited.
+	// +optional
+	MaxAckPending int `json:"maxAckPending,omitempty" protobuf:"varint,2,opt,name=maxAckPending"`
 }
 
 // TLS defines the TLS configuration for the NATS client.
@@ -59,4 +437,70 @@ type Auth struct {
 	// NKey auth
 	// +optional
 	NKey *corev1.SecretKeySelector `json:"nkey,omitempty" protobuf:"bytes,3,opt,name=nkey"`
+	// Credentials auth, which refers to a .creds file containing both the user JWT and the NKey seed
+	// +optional
+	Credentials *corev1.SecretKeySelector `json:"credentials,omitempty" protobuf:"bytes,4,opt,name=credentials"`
+	// JWT auth, which contains the user JWT and the NKey seed in separate secrets
+	// +optional
+	JWT *JWTAuth `json:"jwt,omitempty" protobuf:"bytes,5,opt,name=jwt"`
+}
+
+// JWTAuth represents the decentralized JWT authentication approach with the user JWT and the NKey seed
+// stored separately.
+type JWTAuth struct {
+	// Secret for the user JWT
+	JWT *corev1.SecretKeySelector `json:"jwt,omitempty" protobuf:"bytes,1,opt,name=jwt"`
+	// Secret for the NKey seed used to sign the server nonce
+	Seed *corev1.SecretKeySelector `json:"seed,omitempty" protobuf:"bytes,2,opt,name=seed"`
+}
+
+// EventTimeSource is where the event time of a message is extracted from.
+type EventTimeSource string
+
+const (
+	// EventTimeFromPublishTime uses the time the message was stored in the stream, only available with JetStream.
+	EventTimeFromPublishTime EventTimeSource = "publishTime"
+	// EventTimeFromHeader uses the value of a message header.
+	EventTimeFromHeader EventTimeSource = "header"
+	// EventTimeFromPayload uses the value of a field in the JSON payload.
+	EventTimeFromPayload EventTimeSource = "payload"
+)
+
+// EventTimeFormat is the format of an event time value extracted from a header or the payload.
+type EventTimeFormat string
+
+const (
+	// EventTimeFormatRFC3339 parses the value as an RFC3339 timestamp, e.g. "2023-09-05T19:18:44Z".
+	EventTimeFormatRFC3339 EventTimeFormat = "rfc3339"
+	// EventTimeFormatEpochMillis parses the value as the number of milliseconds since the Unix epoch.
+	EventTimeFormatEpochMillis EventTimeFormat = "epochMillis"
+)
+
+// EventTimeFallback is what happens to a message whose event time cannot be extracted.
+type EventTimeFallback string
+
+const (
+	// EventTimeFallbackNow uses the time the message is read.
+	EventTimeFallbackNow EventTimeFallback = "now"
+	// EventTimeFallbackDrop drops the message. JetStream messages are terminated so that they are not redelivered.
+	EventTimeFallbackDrop EventTimeFallback = "drop"
+)
+
+// EventTime defines how the event time of the messages is extracted.
+type EventTime struct {
+	// Source is where the event time is extracted from.
+	Source EventTimeSource `json:"source" protobuf:"bytes,1,opt,name=source"`
+	// Header is the name of the header holding the event time, required when the source is "header".
+	// +optional
+	Header string `json:"header,omitempty" protobuf:"bytes,2,opt,name=header"`
+	// JSONPath is the dot separated path of the payload field holding the event time,
+	// required when the source is "payload".
+	// +optional
+	JSONPath string `json:"jsonPath,omitempty" protobuf:"bytes,3,opt,name=jsonPath"`
+	// Format is the format of the header or payload value, defaults to "rfc3339".
+	// +optional
+	Format EventTimeFormat `json:"format,omitempty" protobuf:"bytes,4,opt,name=format"`
+	// Fallback is the policy applied when the event time cannot be extracted, defaults to "now".
+	// +optional
+	Fallback EventTimeFallback `json:"fallback,omitempty" protobuf:"bytes,5,opt,name=fallback"`
 }
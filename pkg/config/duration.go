@@ -0,0 +1,35 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Duration is a wrapper around time.Duration which is (un)marshalled as a duration string, e.g. "30s".
+type Duration struct {
+	time.Duration
+}
+
+// MarshalJSON implements the json.Marshaller interface.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Duration.String())
+}
+
+// UnmarshalJSON implements the json.Unmarshaller interface.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var str string
+	if err := json.Unmarshal(b, &str); err != nil {
+		return err
+	}
+	return d.parse(str)
+}
+
+func (d *Duration) parse(str string) error {
+	pd, err := time.ParseDuration(str)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q, %w", str, err)
+	}
+	d.Duration = pd
+	return nil
+}
@@ -4,7 +4,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 
+	"go.uber.org/zap"
 	"gopkg.in/yaml.v2"
 )
 
@@ -14,12 +17,28 @@ type Parser interface {
 	UnParse(config *Config) (string, error)
 }
 
-// YAMLConfigParser is a parser for YAML formatted configuration strings
-type YAMLConfigParser struct{}
+// YAMLConfigParser is a parser for YAML formatted configuration strings.
+// It uses the same field names as the JSON format, so that the same document works in both formats.
+// The legacy lowercase field names, e.g. "insecureskipverify" or "localobjectreference", are still accepted
+// with a deprecation warning.
+type YAMLConfigParser struct {
+	// Logger is used to warn about the deprecated field names, no warning is logged if it is nil.
+	Logger *zap.SugaredLogger
+}
 
 func (p *YAMLConfigParser) Parse(configString string) (*Config, error) {
+	var node yamlNode
+	err := yaml.Unmarshal([]byte(configString), &node)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config string: %w", err)
+	}
+	doc := normalizeYAML(node.value, reflect.TypeOf(Config{}), "", p.warn)
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config string: %w", err)
+	}
 	c := &Config{}
-	err := yaml.Unmarshal([]byte(configString), c)
+	err = json.Unmarshal(b, c)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse config string: %w", err)
 	}
@@ -30,13 +49,192 @@ func (p *YAMLConfigParser) UnParse(config *Config) (string, error) {
 	if config == nil {
 		return "", errors.New("config cannot be nil")
 	}
-	b, err := yaml.Marshal(config)
+	b, err := json.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("failed to un-parse config: %w", err)
+	}
+	// JSON is valid YAML, going through a MapSlice keeps the order of the fields.
+	var doc yaml.MapSlice
+	err = yaml.Unmarshal(b, &doc)
+	if err != nil {
+		return "", fmt.Errorf("failed to un-parse config: %w", err)
+	}
+	b, err = yaml.Marshal(doc)
 	if err != nil {
 		return "", fmt.Errorf("failed to un-parse config: %w", err)
 	}
 	return string(b), nil
 }
 
+func (p *YAMLConfigParser) warn(path, legacyName, name string) {
+	if p.Logger == nil {
+		return
+	}
+	if name == "" {
+		p.Logger.Warnf("Config field %s%s is deprecated, set its fields directly under %s instead", path, legacyName, strings.TrimSuffix(path, "."))
+		return
+	}
+	p.Logger.Warnf("Config field %s%s is deprecated, use %s%s instead", path, legacyName, path, name)
+}
+
+// yamlNode decodes a YAML document into maps, slices and scalars like yaml.v2 does into an interface{},
+// except that the scalars which are not strings are decoded to a yamlScalar.
+type yamlNode struct {
+	value interface{}
+}
+
+func (n *yamlNode) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var v interface{}
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	switch v.(type) {
+	case map[interface{}]interface{}:
+		var nodes map[string]yamlNode
+		if err := unmarshal(&nodes); err != nil {
+			return err
+		}
+		m := make(map[string]interface{}, len(nodes))
+		for k, node := range nodes {
+			m[k] = node.value
+		}
+		n.value = m
+	case []interface{}:
+		var nodes []yamlNode
+		if err := unmarshal(&nodes); err != nil {
+			return err
+		}
+		s := make([]interface{}, len(nodes))
+		for i, node := range nodes {
+			s[i] = node.value
+		}
+		n.value = s
+	case nil, string:
+		n.value = v
+	default:
+		var literal string
+		if err := unmarshal(&literal); err != nil {
+			return err
+		}
+		n.value = yamlScalar{value: v, literal: literal}
+	}
+	return nil
+}
+
+// yamlScalar is a YAML scalar which yaml.v2 resolves to a number or a boolean, e.g. 123 or on.
+// It keeps the literal, so that it can still be set to a string field.
+type yamlScalar struct {
+	value   interface{}
+	literal string
+}
+
+func (s yamlScalar) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.value)
+}
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// normalizeYAML converts a decoded YAML document to its JSON equivalent for the given type.
+// The legacy field names, which are the lowercase Go field names used by yaml.v2 by default, are renamed to
+// the JSON field names, and the legacy nested embedded structs are inlined, reporting each of them to warn
+// (with an empty name for the inlined structs). The numbers and booleans set to string fields are turned back
+// into their literal.
+func normalizeYAML(doc interface{}, t reflect.Type, path string, warn func(path, legacyName, name string)) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch v := doc.(type) {
+	case yamlScalar:
+		if t.Kind() == reflect.String {
+			return v.literal
+		}
+		return v.value
+	case map[string]interface{}:
+		if t.Kind() != reflect.Struct || reflect.PtrTo(t).Implements(jsonUnmarshalerType) {
+			return v
+		}
+		fields := jsonFields(t)
+		m := make(map[string]interface{}, len(v))
+		for k, value := range v {
+			name := k
+			if f, ok := fields.byName[k]; ok {
+				m[name] = normalizeYAML(value, f.Type, path+name+".", warn)
+				continue
+			}
+			if f, ok := fields.byLegacyName[k]; ok {
+				if f.Anonymous {
+					// The embedded struct used to be nested under its type name, its fields are inlined now.
+					warn(path, k, "")
+					if inlined, ok := normalizeYAML(value, f.Type, path, warn).(map[string]interface{}); ok {
+						for ik, iv := range inlined {
+							m[ik] = iv
+						}
+					}
+					continue
+				}
+				name = jsonName(f)
+				warn(path, k, name)
+				m[name] = normalizeYAML(value, f.Type, path+name+".", warn)
+				continue
+			}
+			m[name] = value
+		}
+		return m
+	case []interface{}:
+		if t.Kind() != reflect.Slice {
+			return v
+		}
+		for i := range v {
+			v[i] = normalizeYAML(v[i], t.Elem(), fmt.Sprintf("%s[%d].", strings.TrimSuffix(path, "."), i), warn)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+type structFields struct {
+	byName       map[string]reflect.StructField
+	byLegacyName map[string]reflect.StructField
+}
+
+// jsonFields indexes the fields of a struct by their JSON name, including the fields of inlined embedded structs,
+// and by their legacy yaml.v2 name.
+func jsonFields(t reflect.Type) structFields {
+	fields := structFields{
+		byName:       map[string]reflect.StructField{},
+		byLegacyName: map[string]reflect.StructField{},
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		fields.byLegacyName[strings.ToLower(f.Name)] = f
+		name := jsonName(f)
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			inlined := jsonFields(f.Type)
+			for k, v := range inlined.byName {
+				fields.byName[k] = v
+			}
+			continue
+		}
+		fields.byName[name] = f
+	}
+	return fields
+}
+
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" && !f.Anonymous {
+		return f.Name
+	}
+	return name
+}
+
 // JSONConfigParser is a parser for JSON formatted configuration strings.
 type JSONConfigParser struct{}
 
@@ -4,9 +4,13 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestConfigParser_UnParseThenParse(t *testing.T) {
@@ -14,6 +18,10 @@ func TestConfigParser_UnParseThenParse(t *testing.T) {
 		&JSONConfigParser{},
 		&YAMLConfigParser{},
 	}
+	jitter := 0.1
+	// The times are parsed in the local time zone.
+	startTime := metav1.Date(2023, 7, 1, 12, 0, 0, 0, time.Local)
+	maxReconnects := 0
 	for _, parser := range parsers {
 		testConfig := &Config{
 			URL:     "nats",
@@ -32,6 +40,90 @@ func TestConfigParser_UnParseThenParse(t *testing.T) {
 					},
 				},
 			},
+			JetStream: &JetStream{
+				Stream:        "my-stream",
+				Consumer:      "my-consumer",
+				FilterSubject: "test-subject.>",
+				DeliverPolicy: DeliverByStartTime,
+				AckWait:       &Duration{Duration: 10 * time.Second},
+				StartTime:     &startTime,
+				MaxDeliver:    5,
+				BackOff:       []Duration{{Duration: time.Second}, {Duration: time.Minute}},
+				AckDeadline:   &Duration{Duration: time.Minute},
+			},
+			Keys: &Keys{
+				SubjectTokens: []int{2, 3},
+				Header:        "Tenant",
+				JSONPath:      "customer.id",
+			},
+			EventTime: &EventTime{
+				Source:   EventTimeFromHeader,
+				Header:   "Event-Time",
+				Format:   EventTimeFormatEpochMillis,
+				Fallback: EventTimeFallbackDrop,
+			},
+			Subscriptions: []Subscription{
+				{Subject: "other-subject", Queue: "other-queue"},
+			},
+			Drain: &Drain{
+				Timeout: &Duration{Duration: 5 * time.Second},
+			},
+			Buffer: &Buffer{
+				Size:           100,
+				OverflowPolicy: OverflowDropOldest,
+			},
+			Metrics: &Metrics{
+				Port: 9091,
+			},
+			Health: &Health{
+				Port:                  8081,
+				DisconnectGracePeriod: &Duration{Duration: 2 * time.Minute},
+			},
+			ConnectRetry: &ConnectRetry{
+				MaxAttempts:    5,
+				InitialBackoff: &Duration{Duration: 500 * time.Millisecond},
+				MaxBackoff:     &Duration{Duration: 10 * time.Second},
+				Jitter:         &jitter,
+			},
+			Partitions: &Partitions{
+				Count:           2,
+				SubjectTemplate: "test-subject.{partition}",
+			},
+			DeadLetter: &DeadLetter{
+				Subject:   "test-dead-letter",
+				JetStream: true,
+			},
+			KeyValue: &KeyValue{
+				Bucket:          "test-bucket",
+				Keys:            "test-keys.>",
+				InitialSnapshot: true,
+				Envelope:        true,
+			},
+			Provision: &Provision{
+				DryRun: true,
+				Stream: &StreamProvision{
+					Subjects:  []string{"test-subject.>"},
+					Retention: RetentionWorkQueue,
+					Storage:   StorageMemory,
+					Replicas:  3,
+					MaxAge:    &Duration{Duration: time.Hour},
+				},
+				Consumer: &ConsumerProvision{
+					Replicas:      3,
+					MaxAckPending: 500,
+				},
+			},
+			Connection: &Connection{
+				Name:                 "my-source",
+				ReconnectWait:        &Duration{Duration: 5 * time.Second},
+				MaxReconnects:        &maxReconnects,
+				PingInterval:         &Duration{Duration: time.Minute},
+				MaxPingsOutstanding:  3,
+				InboxPrefix:          "_MY_INBOX",
+				ReconnectBufferSize:  -1,
+				PendingMessagesLimit: 1000,
+				PendingBytesLimit:    1 << 20,
+			},
 		}
 		configStr, err := parser.UnParse(testConfig)
 		assert.NoError(t, err)
@@ -134,3 +226,127 @@ func TestConfigParser_JSON(t *testing.T) {
 		},
 	}, config))
 }
+
+func TestConfigParser_SameDocument(t *testing.T) {
+	// JSON is valid YAML, so the same document must give the same config with both parsers.
+	str := `
+{
+   "url":"nats",
+   "subject":"test-subject",
+   "tls":{
+      "insecureSkipVerify":true,
+      "caCertSecret":{
+         "name":"nats-tls",
+         "key":"ca.crt"
+      }
+   },
+   "jetstream":{
+      "stream":"my-stream",
+      "consumer":"my-consumer",
+      "deliverPolicy":"new",
+      "ackWait":"10s"
+   },
+   "keys":{
+      "subjectTokens":[2],
+      "jsonPath":"customer.id"
+   },
+   "subscriptions":[
+      {
+         "subject":"other-subject",
+         "queue":"other-queue"
+      }
+   ],
+   "buffer":{
+      "size":100,
+      "overflowPolicy":"dropOldest"
+   }
+}
+`
+	jsonConfig, err := (&JSONConfigParser{}).Parse(str)
+	assert.NoError(t, err)
+	yamlConfig, err := (&YAMLConfigParser{}).Parse(str)
+	assert.NoError(t, err)
+	assert.Equal(t, jsonConfig, yamlConfig)
+	assert.True(t, jsonConfig.TLS.InsecureSkipVerify)
+	assert.Equal(t, "nats-tls", yamlConfig.TLS.CACertSecret.Name)
+	assert.Equal(t, OverflowDropOldest, yamlConfig.Buffer.OverflowPolicy)
+}
+
+func TestConfigParser_YAMLDeprecatedFields(t *testing.T) {
+	yamlStr := `
+url: nats
+subject: test-subject
+tls:
+  insecureskipverify: true
+auth:
+  basic:
+    user:
+      localobjectreference:
+        name: nats-auth-fake-token
+      key: fake-token
+jetstream:
+  stream: my-stream
+  deliverpolicy: new
+  ackwait: 10s
+`
+	core, logs := observer.New(zap.WarnLevel)
+	parser := &YAMLConfigParser{Logger: zap.New(core).Sugar()}
+	config, err := parser.Parse(yamlStr)
+	assert.NoError(t, err)
+	assert.True(t, config.TLS.InsecureSkipVerify)
+	assert.Equal(t, "nats-auth-fake-token", config.Auth.Basic.User.Name)
+	assert.Equal(t, DeliverNew, config.JetStream.DeliverPolicy)
+	assert.Equal(t, 10*time.Second, config.JetStream.AckWait.Duration)
+
+	var messages []string
+	for _, entry := range logs.All() {
+		messages = append(messages, entry.Message)
+	}
+	assert.ElementsMatch(t, []string{
+		"Config field tls.insecureskipverify is deprecated, use tls.insecureSkipVerify instead",
+		"Config field auth.basic.user.localobjectreference is deprecated, set its fields directly under auth.basic.user instead",
+		"Config field jetstream.deliverpolicy is deprecated, use jetstream.deliverPolicy instead",
+		"Config field jetstream.ackwait is deprecated, use jetstream.ackWait instead",
+	}, messages)
+}
+
+func TestConfigParser_YAMLScalarStrings(t *testing.T) {
+	// yaml.v2 resolves plain scalars with YAML 1.1, e.g. 123 to a number and on or n to a boolean,
+	// they must still be accepted by the string fields.
+	yamlStr := `
+url: nats
+subject: 123
+queue: 1.5
+tls:
+  insecureSkipVerify: yes
+auth:
+  basic:
+    user:
+      name: n
+      key: off
+    password:
+      name: on
+      key: 0x10
+subscriptions:
+- subject: true
+  queue: no
+buffer:
+  size: 100
+jetstream:
+  stream: y
+  consumer: ~
+`
+	config, err := (&YAMLConfigParser{}).Parse(yamlStr)
+	assert.NoError(t, err)
+	assert.Equal(t, "123", config.Subject)
+	assert.Equal(t, "1.5", config.Queue)
+	assert.True(t, config.TLS.InsecureSkipVerify)
+	assert.Equal(t, "n", config.Auth.Basic.User.Name)
+	assert.Equal(t, "off", config.Auth.Basic.User.Key)
+	assert.Equal(t, "on", config.Auth.Basic.Password.Name)
+	assert.Equal(t, "0x10", config.Auth.Basic.Password.Key)
+	assert.Equal(t, []Subscription{{Subject: "true", Queue: "no"}}, config.Subscriptions)
+	assert.Equal(t, 100, config.Buffer.Size)
+	assert.Equal(t, "y", config.JetStream.Stream)
+	assert.Empty(t, config.JetStream.Consumer)
+}
@@ -0,0 +1,388 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// defaultAckWait is the AckWait the server gives a consumer created without one.
+const defaultAckWait = 30 * time.Second
+
+// Validate checks the config and returns all the problems found at once, each prefixed with the path of the field.
+func (c *Config) Validate() error {
+	v := &validator{}
+	if c.URL == "" {
+		v.add("url", "is required")
+	}
+	switch {
+	case c.KeyValue != nil:
+		v.validateKeyValue("keyValue", c)
+	case c.Partitions != nil:
+		v.validatePartitions("partitions", c)
+	case c.JetStream == nil:
+		if len(c.GetSubscriptions()) == 0 {
+			v.add("subject", "is required when no subscriptions are specified")
+		}
+		for i, s := range c.Subscriptions {
+			if s.Subject == "" {
+				v.add(fmt.Sprintf("subscriptions[%d].subject", i), "is required")
+			}
+		}
+	}
+	if c.JetStream != nil {
+		v.validateJetStream("jetstream", c)
+	}
+	if c.TLS != nil {
+		v.validateTLS("tls", c.TLS)
+	}
+	if c.Auth != nil {
+		v.validateAuth("auth", c.Auth)
+	}
+	if c.Keys != nil {
+		for i, position := range c.Keys.SubjectTokens {
+			if position < 1 {
+				v.add(fmt.Sprintf("keys.subjectTokens[%d]", i), "must be a 1-based position, got %d", position)
+			}
+		}
+	}
+	if c.EventTime != nil {
+		v.validateEventTime("eventTime", c)
+	}
+	if c.Drain != nil && c.Drain.Timeout != nil && c.Drain.Timeout.Duration <= 0 {
+		v.add("drain.timeout", "must be positive")
+	}
+	if c.Buffer != nil {
+		if c.Buffer.Size < 0 {
+			v.add("buffer.size", "must not be negative")
+		}
+		switch c.Buffer.OverflowPolicy {
+		case "", OverflowBlock, OverflowDropNewest, OverflowDropOldest:
+		default:
+			v.add("buffer.overflowPolicy", "unsupported value %q", c.Buffer.OverflowPolicy)
+		}
+	}
+	if c.Metrics != nil && (c.Metrics.Port < 0 || c.Metrics.Port > 65535) {
+		v.add("metrics.port", "must be between 0 and 65535")
+	}
+	if c.Health != nil {
+		if c.Health.Port < 0 || c.Health.Port > 65535 {
+			v.add("health.port", "must be between 0 and 65535")
+		}
+		if c.Health.DisconnectGracePeriod != nil && c.Health.DisconnectGracePeriod.Duration < 0 {
+			v.add("health.disconnectGracePeriod", "must not be negative")
+		}
+	}
+	if c.ConnectRetry != nil {
+		v.validateConnectRetry("connectRetry", c.ConnectRetry)
+	}
+	if c.Connection != nil {
+		v.validateConnection("connection", c.Connection)
+	}
+	if c.DeadLetter != nil {
+		if c.DeadLetter.Subject == "" {
+			v.add("deadLetter.subject", "is required")
+		} else if strings.ContainsAny(c.DeadLetter.Subject, "*>") {
+			v.add("deadLetter.subject", "must not contain wildcards")
+		}
+	}
+	if c.Provision != nil {
+		v.validateProvision("provision", c)
+	}
+	return errors.Join(v.errs...)
+}
+
+// validator collects the validation errors of the config.
+type validator struct {
+	errs []error
+}
+
+func (v *validator) add(path, format string, args ...interface{}) {
+	v.errs = append(v.errs, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+}
+
+func (v *validator) validateConnectRetry(path string, r *ConnectRetry) {
+	if r.InitialBackoff != nil && r.InitialBackoff.Duration <= 0 {
+		v.add(path+".initialBackoff", "must be positive")
+	}
+	if r.MaxBackoff != nil && r.MaxBackoff.Duration <= 0 {
+		v.add(path+".maxBackoff", "must be positive")
+	}
+	if r.InitialBackoff != nil && r.MaxBackoff != nil && r.MaxBackoff.Duration < r.InitialBackoff.Duration {
+		v.add(path+".maxBackoff", "must not be less than initialBackoff")
+	}
+	if r.Jitter != nil && (*r.Jitter < 0 || *r.Jitter > 1) {
+		v.add(path+".jitter", "must be between 0 and 1")
+	}
+}
+
+func (v *validator) validateConnection(path string, c *Connection) {
+	if c.ReconnectWait != nil && c.ReconnectWait.Duration <= 0 {
+		v.add(path+".reconnectWait", "must be positive")
+	}
+	if c.MaxReconnects != nil && *c.MaxReconnects < -1 {
+		v.add(path+".maxReconnects", "must be -1 or more")
+	}
+	if c.PingInterval != nil && c.PingInterval.Duration <= 0 {
+		v.add(path+".pingInterval", "must be positive")
+	}
+	if c.MaxPingsOutstanding < 0 {
+		v.add(path+".maxPingsOutstanding", "must not be negative")
+	}
+	if c.InboxPrefix != "" && (strings.ContainsAny(c.InboxPrefix, "*>") || strings.HasSuffix(c.InboxPrefix, ".")) {
+		v.add(path+".inboxPrefix", "must not contain wildcards or end with a dot")
+	}
+	if c.ReconnectBufferSize < -1 {
+		v.add(path+".reconnectBufferSize", "must be -1 or more")
+	}
+	if c.PendingMessagesLimit < -1 {
+		v.add(path+".pendingMessagesLimit", "must be -1 or more")
+	}
+	if c.PendingBytesLimit < -1 {
+		v.add(path+".pendingBytesLimit", "must be -1 or more")
+	}
+}
+
+func (v *validator) validatePartitions(path string, c *Config) {
+	p := c.Partitions
+	if p.Count < 1 {
+		v.add(path+".count", "must be positive")
+	}
+	if !strings.Contains(p.SubjectTemplate, PartitionPlaceholder) {
+		v.add(path+".subjectTemplate", "must contain %s", PartitionPlaceholder)
+	}
+	// With JetStream, the subscriptions are already reported as not supported.
+	if len(c.Subscriptions) > 0 && c.JetStream == nil {
+		v.add("subscriptions", "is not supported with partitions, use partitions.subjectTemplate instead")
+	}
+}
+
+func (v *validator) validateJetStream(path string, c *Config) {
+	js := c.JetStream
+	if js.Stream == "" {
+		v.add(path+".stream", "is required")
+	}
+	if js.Consumer == "" {
+		v.add(path+".consumer", "is required")
+	}
+	if c.Partitions != nil {
+		if js.FilterSubject != "" {
+			v.add(path+".filterSubject", "is not supported with partitions, use partitions.subjectTemplate instead")
+		}
+	} else if js.FilterSubject == "" && c.Subject == "" {
+		v.add(path+".filterSubject", "is required when subject is not specified")
+	}
+	switch js.DeliverPolicy {
+	case "", DeliverAll, DeliverLast, DeliverNew, DeliverLastPerSubject:
+	case DeliverByStartSequence:
+		if js.StartSequence == 0 {
+			v.add(path+".startSequence", "is required when deliverPolicy is %q", js.DeliverPolicy)
+		}
+	case DeliverByStartTime:
+		if js.StartTime == nil {
+			v.add(path+".startTime", "is required when deliverPolicy is %q", js.DeliverPolicy)
+		}
+	default:
+		v.add(path+".deliverPolicy", "unsupported value %q", js.DeliverPolicy)
+	}
+	if js.MaxDeliver < -1 {
+		v.add(path+".maxDeliver", "must be -1 or more")
+	}
+	for i, d := range js.BackOff {
+		if d.Duration <= 0 {
+			v.add(fmt.Sprintf("%s.backOff[%d]", path, i), "must be positive")
+		}
+	}
+	if len(js.BackOff) > 0 && js.MaxDeliver <= len(js.BackOff) {
+		v.add(path+".maxDeliver", "must be greater than the number of backOff delays, %d", len(js.BackOff))
+	}
+	if js.AckDeadline != nil {
+		if js.AckDeadline.Duration <= 0 {
+			v.add(path+".ackDeadline", "must be positive")
+		} else if redelivery := redeliveryDelay(js); js.AckDeadline.Duration >= redelivery {
+			v.add(path+".ackDeadline", "must be shorter than the redelivery delay of the server, %s", redelivery)
+		}
+	}
+	if js.StartSequence != 0 && js.DeliverPolicy != DeliverByStartSequence {
+		v.add(path+".startSequence", "is only supported when deliverPolicy is %q", DeliverByStartSequence)
+	}
+	if js.StartTime != nil && js.DeliverPolicy != DeliverByStartTime {
+		v.add(path+".startTime", "is only supported when deliverPolicy is %q", DeliverByStartTime)
+	}
+	if js.AckWait != nil && js.AckWait.Duration <= 0 {
+		v.add(path+".ackWait", "must be positive")
+	}
+	if len(c.Subscriptions) > 0 {
+		v.add("subscriptions", "is not supported with jetstream, use jetstream.filterSubject instead")
+	}
+}
+
+// redeliveryDelay returns the shortest delay after which the server redelivers a message which is not acknowledged,
+// the smallest backOff delay when set, AckWait otherwise.
+func redeliveryDelay(js *JetStream) time.Duration {
+	if len(js.BackOff) > 0 {
+		delay := js.BackOff[0].Duration
+		for _, d := range js.BackOff[1:] {
+			if d.Duration < delay {
+				delay = d.Duration
+			}
+		}
+		return delay
+	}
+	if js.AckWait != nil {
+		return js.AckWait.Duration
+	}
+	return defaultAckWait
+}
+
+func (v *validator) validateKeyValue(path string, c *Config) {
+	if c.KeyValue.Bucket == "" {
+		v.add(path+".bucket", "is required")
+	}
+	for _, f := range []struct {
+		path string
+		set  bool
+	}{
+		{"subject", c.Subject != ""},
+		{"subscriptions", len(c.Subscriptions) > 0},
+		{"jetstream", c.JetStream != nil},
+		{"partitions", c.Partitions != nil},
+		{"keys", c.Keys != nil},
+		{"eventTime", c.EventTime != nil},
+	} {
+		if f.set {
+			v.add(f.path, "is not supported with keyValue")
+		}
+	}
+}
+
+func (v *validator) validateProvision(path string, c *Config) {
+	if c.JetStream == nil {
+		v.add(path, "requires jetstream")
+	}
+	if s := c.Provision.Stream; s != nil {
+		if len(s.Subjects) == 0 {
+			v.add(path+".stream.subjects", "is required")
+		}
+		switch s.Retention {
+		case "", RetentionLimits, RetentionInterest, RetentionWorkQueue:
+		default:
+			v.add(path+".stream.retention", "unsupported value %q", s.Retention)
+		}
+		switch s.Storage {
+		case "", StorageFile, StorageMemory:
+		default:
+			v.add(path+".stream.storage", "unsupported value %q", s.Storage)
+		}
+		if s.Replicas < 0 {
+			v.add(path+".stream.replicas", "must not be negative")
+		}
+		if s.MaxAge != nil && s.MaxAge.Duration <= 0 {
+			v.add(path+".stream.maxAge", "must be positive")
+		}
+	}
+	if cp := c.Provision.Consumer; cp != nil {
+		if cp.Replicas < 0 {
+			v.add(path+".consumer.replicas", "must not be negative")
+		}
+		if cp.MaxAckPending < -1 {
+			v.add(path+".consumer.maxAckPending", "must be -1 or more")
+		}
+	}
+}
+
+func (v *validator) validateTLS(path string, tls *TLS) {
+	v.validateSecret(path+".caCertSecret", tls.CACertSecret)
+	v.validateSecret(path+".clientCertSecret", tls.CertSecret)
+	v.validateSecret(path+".clientKeySecret", tls.KeySecret)
+	if tls.CertSecret != nil && tls.KeySecret == nil {
+		v.add(path+".clientKeySecret", "is required when clientCertSecret is specified")
+	}
+	if tls.KeySecret != nil && tls.CertSecret == nil {
+		v.add(path+".clientCertSecret", "is required when clientKeySecret is specified")
+	}
+}
+
+func (v *validator) validateAuth(path string, auth *Auth) {
+	var methods []string
+	if auth.Basic != nil {
+		methods = append(methods, "basic")
+		v.validateRequiredSecret(path+".basic.user", auth.Basic.User)
+		v.validateRequiredSecret(path+".basic.password", auth.Basic.Password)
+	}
+	if auth.Token != nil {
+		methods = append(methods, "token")
+		v.validateSecret(path+".token", auth.Token)
+	}
+	if auth.NKey != nil {
+		methods = append(methods, "nkey")
+		v.validateSecret(path+".nkey", auth.NKey)
+	}
+	if auth.Credentials != nil {
+		methods = append(methods, "credentials")
+		v.validateSecret(path+".credentials", auth.Credentials)
+	}
+	if auth.JWT != nil {
+		methods = append(methods, "jwt")
+		v.validateRequiredSecret(path+".jwt.jwt", auth.JWT.JWT)
+		v.validateRequiredSecret(path+".jwt.seed", auth.JWT.Seed)
+	}
+	if len(methods) > 1 {
+		v.add(path, "only one authentication method can be specified, got %v", methods)
+	}
+}
+
+func (v *validator) validateEventTime(path string, c *Config) {
+	et := c.EventTime
+	switch et.Source {
+	case EventTimeFromPublishTime:
+		if c.JetStream == nil {
+			v.add(path+".source", "%q requires jetstream", et.Source)
+		}
+	case EventTimeFromHeader:
+		if et.Header == "" {
+			v.add(path+".header", "is required when source is %q", et.Source)
+		}
+	case EventTimeFromPayload:
+		if et.JSONPath == "" {
+			v.add(path+".jsonPath", "is required when source is %q", et.Source)
+		}
+	case "":
+		v.add(path+".source", "is required")
+	default:
+		v.add(path+".source", "unsupported value %q", et.Source)
+	}
+	switch et.Format {
+	case "", EventTimeFormatRFC3339, EventTimeFormatEpochMillis:
+	default:
+		v.add(path+".format", "unsupported value %q", et.Format)
+	}
+	switch et.Fallback {
+	case "", EventTimeFallbackNow, EventTimeFallbackDrop:
+	default:
+		v.add(path+".fallback", "unsupported value %q", et.Fallback)
+	}
+}
+
+func (v *validator) validateRequiredSecret(path string, selector *corev1.SecretKeySelector) {
+	if selector == nil {
+		v.add(path, "is required")
+		return
+	}
+	v.validateSecret(path, selector)
+}
+
+func (v *validator) validateSecret(path string, selector *corev1.SecretKeySelector) {
+	if selector == nil {
+		return
+	}
+	if selector.Name == "" {
+		v.add(path+".name", "is required")
+	}
+	if selector.Key == "" {
+		v.add(path+".key", "is required")
+	}
+}
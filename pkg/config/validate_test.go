@@ -0,0 +1,435 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func secret(name, key string) *corev1.SecretKeySelector {
+	return &corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{
+			Name: name,
+		},
+		Key: key,
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	invalidJitter := 1.5
+	invalidMaxReconnects := -2
+	tests := []struct {
+		name   string
+		config *Config
+		errs   []string
+	}{
+		{
+			name: "valid core nats",
+			config: &Config{
+				URL:     "nats",
+				Subject: "test-subject",
+				Queue:   "my-queue",
+				Auth: &Auth{
+					Token: secret("nats-auth-fake-token", "fake-token"),
+				},
+			},
+		},
+		{
+			name: "valid jetstream",
+			config: &Config{
+				URL: "nats",
+				JetStream: &JetStream{
+					Stream:        "my-stream",
+					Consumer:      "my-consumer",
+					FilterSubject: "test-subject",
+					AckWait:       &Duration{Duration: time.Minute},
+					AckDeadline:   &Duration{Duration: 30 * time.Second},
+				},
+				EventTime: &EventTime{
+					Source: EventTimeFromPublishTime,
+				},
+			},
+		},
+		{
+			name:   "missing url and subject",
+			config: &Config{},
+			errs: []string{
+				"url: is required",
+				"subject: is required when no subscriptions are specified",
+			},
+		},
+		{
+			name: "subscriptions without subject",
+			config: &Config{
+				URL:           "nats",
+				Subscriptions: []Subscription{{Subject: "a"}, {Queue: "q"}},
+			},
+			errs: []string{
+				"subscriptions[1].subject: is required",
+			},
+		},
+		{
+			name: "multiple auth methods and incomplete secrets",
+			config: &Config{
+				URL:     "nats",
+				Subject: "test-subject",
+				Auth: &Auth{
+					Basic: &BasicAuth{
+						User: secret("nats-auth", "user"),
+					},
+					Token: secret("nats-auth", ""),
+				},
+			},
+			errs: []string{
+				"auth.basic.password: is required",
+				"auth.token.key: is required",
+				"auth: only one authentication method can be specified, got [basic token]",
+			},
+		},
+		{
+			name: "cert without key",
+			config: &Config{
+				URL:     "nats",
+				Subject: "test-subject",
+				TLS: &TLS{
+					CertSecret: secret("nats-tls", "cert"),
+				},
+			},
+			errs: []string{
+				"tls.clientKeySecret: is required when clientCertSecret is specified",
+			},
+		},
+		{
+			name: "invalid jetstream",
+			config: &Config{
+				URL: "nats",
+				JetStream: &JetStream{
+					DeliverPolicy: "first",
+					AckWait:       &Duration{},
+				},
+				Subscriptions: []Subscription{{Subject: "a"}},
+			},
+			errs: []string{
+				"jetstream.stream: is required",
+				"jetstream.consumer: is required",
+				"jetstream.filterSubject: is required when subject is not specified",
+				`jetstream.deliverPolicy: unsupported value "first"`,
+				"jetstream.ackWait: must be positive",
+				"subscriptions: is not supported with jetstream, use jetstream.filterSubject instead",
+			},
+		},
+		{
+			name: "invalid extraction",
+			config: &Config{
+				URL:     "nats",
+				Subject: "test-subject",
+				Keys: &Keys{
+					SubjectTokens: []int{1, 0},
+				},
+				EventTime: &EventTime{
+					Source:   EventTimeFromHeader,
+					Format:   "unix",
+					Fallback: "skip",
+				},
+			},
+			errs: []string{
+				"keys.subjectTokens[1]: must be a 1-based position, got 0",
+				`eventTime.header: is required when source is "header"`,
+				`eventTime.format: unsupported value "unix"`,
+				`eventTime.fallback: unsupported value "skip"`,
+			},
+		},
+		{
+			name: "publish time without jetstream",
+			config: &Config{
+				URL:     "nats",
+				Subject: "test-subject",
+				EventTime: &EventTime{
+					Source: EventTimeFromPublishTime,
+				},
+			},
+			errs: []string{
+				`eventTime.source: "publishTime" requires jetstream`,
+			},
+		},
+		{
+			name: "invalid buffer and drain",
+			config: &Config{
+				URL:     "nats",
+				Subject: "test-subject",
+				Drain: &Drain{
+					Timeout: &Duration{Duration: -1},
+				},
+				Buffer: &Buffer{
+					Size:           -1,
+					OverflowPolicy: "dropAll",
+				},
+			},
+			errs: []string{
+				"drain.timeout: must be positive",
+				"buffer.size: must not be negative",
+				`buffer.overflowPolicy: unsupported value "dropAll"`,
+			},
+		},
+		{
+			name: "invalid metrics and health",
+			config: &Config{
+				URL:     "nats",
+				Subject: "test-subject",
+				Metrics: &Metrics{
+					Port: 70000,
+				},
+				Health: &Health{
+					Port:                  -1,
+					DisconnectGracePeriod: &Duration{Duration: -time.Second},
+				},
+			},
+			errs: []string{
+				"metrics.port: must be between 0 and 65535",
+				"health.port: must be between 0 and 65535",
+				"health.disconnectGracePeriod: must not be negative",
+			},
+		},
+		{
+			name: "invalid connect retry",
+			config: &Config{
+				URL:     "nats",
+				Subject: "test-subject",
+				ConnectRetry: &ConnectRetry{
+					InitialBackoff: &Duration{Duration: time.Minute},
+					MaxBackoff:     &Duration{Duration: time.Second},
+					Jitter:         &invalidJitter,
+				},
+			},
+			errs: []string{
+				"connectRetry.maxBackoff: must not be less than initialBackoff",
+				"connectRetry.jitter: must be between 0 and 1",
+			},
+		},
+		{
+			name: "replay by start time",
+			config: &Config{
+				URL:     "nats",
+				Subject: "test-subject",
+				JetStream: &JetStream{
+					Stream:        "my-stream",
+					Consumer:      "my-replay-consumer",
+					DeliverPolicy: DeliverByStartTime,
+					StartTime:     &metav1.Time{Time: time.Now()},
+				},
+			},
+		},
+		{
+			name: "invalid replay",
+			config: &Config{
+				URL:     "nats",
+				Subject: "test-subject",
+				JetStream: &JetStream{
+					Stream:        "my-stream",
+					Consumer:      "my-replay-consumer",
+					DeliverPolicy: DeliverByStartSequence,
+					StartTime:     &metav1.Time{Time: time.Now()},
+				},
+			},
+			errs: []string{
+				`jetstream.startSequence: is required when deliverPolicy is "byStartSequence"`,
+				`jetstream.startTime: is only supported when deliverPolicy is "byStartTime"`,
+			},
+		},
+		{
+			name: "invalid redelivery",
+			config: &Config{
+				URL:     "nats",
+				Subject: "test-subject",
+				JetStream: &JetStream{
+					Stream:      "my-stream",
+					Consumer:    "my-consumer",
+					MaxDeliver:  2,
+					BackOff:     []Duration{{Duration: time.Second}, {Duration: 0}},
+					AckDeadline: &Duration{Duration: -time.Second},
+				},
+			},
+			errs: []string{
+				"jetstream.backOff[1]: must be positive",
+				"jetstream.maxDeliver: must be greater than the number of backOff delays, 2",
+				"jetstream.ackDeadline: must be positive",
+			},
+		},
+		{
+			name: "ack deadline beyond default ack wait",
+			config: &Config{
+				URL:     "nats",
+				Subject: "test-subject",
+				JetStream: &JetStream{
+					Stream:      "my-stream",
+					Consumer:    "my-consumer",
+					AckDeadline: &Duration{Duration: 30 * time.Second},
+				},
+			},
+			errs: []string{
+				"jetstream.ackDeadline: must be shorter than the redelivery delay of the server, 30s",
+			},
+		},
+		{
+			name: "ack deadline beyond back off",
+			config: &Config{
+				URL:     "nats",
+				Subject: "test-subject",
+				JetStream: &JetStream{
+					Stream:      "my-stream",
+					Consumer:    "my-consumer",
+					AckWait:     &Duration{Duration: time.Minute},
+					MaxDeliver:  3,
+					BackOff:     []Duration{{Duration: 5 * time.Second}, {Duration: time.Second}},
+					AckDeadline: &Duration{Duration: 2 * time.Second},
+				},
+			},
+			errs: []string{
+				"jetstream.ackDeadline: must be shorter than the redelivery delay of the server, 1s",
+			},
+		},
+		{
+			name: "invalid dead letter",
+			config: &Config{
+				URL:     "nats",
+				Subject: "test-subject",
+				DeadLetter: &DeadLetter{
+					Subject: "dead-letter.>",
+				},
+			},
+			errs: []string{
+				"deadLetter.subject: must not contain wildcards",
+			},
+		},
+		{
+			name: "invalid provision",
+			config: &Config{
+				URL:     "nats",
+				Subject: "test-subject",
+				Provision: &Provision{
+					Stream: &StreamProvision{
+						Retention: "workqueue",
+						Storage:   "disk",
+						Replicas:  -1,
+						MaxAge:    &Duration{},
+					},
+					Consumer: &ConsumerProvision{
+						MaxAckPending: -2,
+					},
+				},
+			},
+			errs: []string{
+				"provision: requires jetstream",
+				"provision.stream.subjects: is required",
+				`provision.stream.retention: unsupported value "workqueue"`,
+				`provision.stream.storage: unsupported value "disk"`,
+				"provision.stream.replicas: must not be negative",
+				"provision.stream.maxAge: must be positive",
+				"provision.consumer.maxAckPending: must be -1 or more",
+			},
+		},
+		{
+			name: "valid key value",
+			config: &Config{
+				URL: "nats",
+				KeyValue: &KeyValue{
+					Bucket: "my-bucket",
+					Keys:   "orders.>",
+				},
+			},
+		},
+		{
+			name: "invalid key value",
+			config: &Config{
+				URL:     "nats",
+				Subject: "test-subject",
+				JetStream: &JetStream{
+					Stream:   "my-stream",
+					Consumer: "my-consumer",
+				},
+				EventTime: &EventTime{
+					Source: EventTimeFromPublishTime,
+				},
+				KeyValue: &KeyValue{},
+			},
+			errs: []string{
+				"keyValue.bucket: is required",
+				"subject: is not supported with keyValue",
+				"jetstream: is not supported with keyValue",
+				"eventTime: is not supported with keyValue",
+			},
+		},
+		{
+			name: "valid partitions",
+			config: &Config{
+				URL: "nats",
+				JetStream: &JetStream{
+					Stream:   "my-stream",
+					Consumer: "my-consumer",
+				},
+				Partitions: &Partitions{
+					Count:           3,
+					SubjectTemplate: "orders.{partition}.>",
+				},
+			},
+		},
+		{
+			name: "invalid partitions",
+			config: &Config{
+				URL: "nats",
+				JetStream: &JetStream{
+					Stream:        "my-stream",
+					Consumer:      "my-consumer",
+					FilterSubject: "orders.>",
+				},
+				Partitions: &Partitions{
+					SubjectTemplate: "orders.>",
+				},
+			},
+			errs: []string{
+				"partitions.count: must be positive",
+				"partitions.subjectTemplate: must contain {partition}",
+				"jetstream.filterSubject: is not supported with partitions, use partitions.subjectTemplate instead",
+			},
+		},
+		{
+			name: "invalid connection",
+			config: &Config{
+				URL:     "nats",
+				Subject: "test-subject",
+				Connection: &Connection{
+					ReconnectWait:        &Duration{},
+					MaxReconnects:        &invalidMaxReconnects,
+					InboxPrefix:          "_INBOX.>",
+					ReconnectBufferSize:  -2,
+					PendingMessagesLimit: -1,
+					PendingBytesLimit:    -5,
+				},
+			},
+			errs: []string{
+				"connection.reconnectWait: must be positive",
+				"connection.maxReconnects: must be -1 or more",
+				"connection.inboxPrefix: must not contain wildcards or end with a dot",
+				"connection.reconnectBufferSize: must be -1 or more",
+				"connection.pendingBytesLimit: must be -1 or more",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if len(tt.errs) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Error(t, err)
+			for _, e := range tt.errs {
+				assert.Contains(t, err.Error(), e)
+			}
+			assert.Equal(t, len(tt.errs), len(err.(interface{ Unwrap() []error }).Unwrap()))
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/numaproj-contrib/nats-source-go/pkg/utils"
+)
+
+const (
+	// DefaultPort is the port the probes are served on when none is configured.
+	DefaultPort = 8080
+	// LivePath is the HTTP path of the liveness probe.
+	LivePath = "/livez"
+	// ReadyPath is the HTTP path of the readiness probe.
+	ReadyPath = "/readyz"
+)
+
+// Checker reports the health of the source.
+type Checker interface {
+	// Live returns an error when the source is stuck and has to be restarted.
+	Live() error
+	// Ready returns an error when the source cannot receive messages.
+	Ready() error
+}
+
+// Deferred is a Checker for a source which is being created, e.g. while it connects to NATS,
+// so that the probes can be served before. It is live but not ready until the source is set.
+type Deferred struct {
+	mu      sync.RWMutex
+	checker Checker
+}
+
+// Set sets the checker of the created source, which the probes are delegated to from now on.
+func (d *Deferred) Set(c Checker) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.checker = c
+}
+
+func (d *Deferred) get() Checker {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.checker
+}
+
+func (d *Deferred) Live() error {
+	if c := d.get(); c != nil {
+		return c.Live()
+	}
+	return nil
+}
+
+func (d *Deferred) Ready() error {
+	if c := d.get(); c != nil {
+		return c.Ready()
+	}
+	return errors.New("source is starting")
+}
+
+// Handler returns the HTTP handler serving the liveness and readiness probes of the checker.
+// A probe responds with 200 when the check passes, and 503 with the error otherwise.
+func Handler(c Checker) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(LivePath, probe(c.Live))
+	mux.HandleFunc(ReadyPath, probe(c.Ready))
+	return mux
+}
+
+func probe(check func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		if err := check(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}
+}
+
+// Serve serves the probes of the checker on the given port until the context is cancelled.
+func Serve(ctx context.Context, port int, c Checker, logger *zap.SugaredLogger) error {
+	logger.Infof("Serving health probes on port %d", port)
+	if err := utils.ListenAndServe(ctx, port, Handler(c)); err != nil {
+		return fmt.Errorf("failed to serve health probes, %w", err)
+	}
+	return nil
+}
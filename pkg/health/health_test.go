@@ -0,0 +1,69 @@
+package health
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeChecker struct {
+	live  error
+	ready error
+}
+
+func (c *fakeChecker) Live() error {
+	return c.live
+}
+
+func (c *fakeChecker) Ready() error {
+	return c.ready
+}
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name    string
+		checker *fakeChecker
+		path    string
+		code    int
+		body    string
+	}{
+		{name: "live", checker: &fakeChecker{}, path: LivePath, code: http.StatusOK, body: "ok"},
+		{name: "ready", checker: &fakeChecker{}, path: ReadyPath, code: http.StatusOK, body: "ok"},
+		{
+			name:    "not live",
+			checker: &fakeChecker{live: errors.New("disconnected for 1m0s")},
+			path:    LivePath,
+			code:    http.StatusServiceUnavailable,
+			body:    "disconnected for 1m0s\n",
+		},
+		{
+			name:    "not ready",
+			checker: &fakeChecker{ready: errors.New("not connected")},
+			path:    ReadyPath,
+			code:    http.StatusServiceUnavailable,
+			body:    "not connected\n",
+		},
+		{name: "unknown path", checker: &fakeChecker{}, path: "/healthz", code: http.StatusNotFound, body: "404 page not found\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			Handler(tt.checker).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			assert.Equal(t, tt.code, rec.Code)
+			assert.Equal(t, tt.body, rec.Body.String())
+		})
+	}
+}
+
+func TestDeferred(t *testing.T) {
+	d := &Deferred{}
+	assert.NoError(t, d.Live())
+	assert.EqualError(t, d.Ready(), "source is starting")
+
+	d.Set(&fakeChecker{live: errors.New("disconnected for 1m0s"), ready: errors.New("not connected")})
+	assert.EqualError(t, d.Live(), "disconnected for 1m0s")
+	assert.EqualError(t, d.Ready(), "not connected")
+}
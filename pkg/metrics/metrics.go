@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+
+	"github.com/numaproj-contrib/nats-source-go/pkg/utils"
+)
+
+const (
+	namespace = "nats_source"
+
+	// LabelSubject is the subject of the subscription which received the message.
+	LabelSubject = "subject"
+	// LabelReason is the reason why the message was dropped.
+	LabelReason = "reason"
+
+	// ReasonBufferFull is used for the messages dropped by the buffer overflow policy.
+	ReasonBufferFull = "bufferFull"
+	// ReasonInvalid is used for the messages dropped because they could not be converted, e.g. missing event time.
+	ReasonInvalid = "invalid"
+
+	// DefaultPort is the port the metrics are served on when none is configured.
+	DefaultPort = 9090
+	// Path is the HTTP path the metrics are served on.
+	Path = "/metrics"
+)
+
+// Metrics holds the Prometheus metrics of a NATS source, registered in their own registry.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	MessagesReceived *prometheus.CounterVec
+	BytesReceived    *prometheus.CounterVec
+	MessagesRead     *prometheus.CounterVec
+	MessagesDropped  *prometheus.CounterVec
+	// MessagesDeadLettered is the number of messages republished to the dead-letter subject.
+	MessagesDeadLettered *prometheus.CounterVec
+	// ReadLatency is the time the messages wait in the buffer before being read.
+	ReadLatency *prometheus.HistogramVec
+	Disconnects prometheus.Counter
+	Reconnects  prometheus.Counter
+}
+
+// New creates the metrics, along with the Go runtime and process collectors.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		MessagesReceived: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_received_total",
+			Help:      "Number of messages received from NATS.",
+		}, []string{LabelSubject}),
+		BytesReceived: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "bytes_received_total",
+			Help:      "Number of payload bytes received from NATS.",
+		}, []string{LabelSubject}),
+		MessagesRead: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_read_total",
+			Help:      "Number of messages read out by Numaflow.",
+		}, []string{LabelSubject}),
+		MessagesDropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_dropped_total",
+			Help:      "Number of messages dropped before being read.",
+		}, []string{LabelSubject, LabelReason}),
+		MessagesDeadLettered: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_dead_lettered_total",
+			Help:      "Number of messages republished to the dead-letter subject.",
+		}, []string{LabelSubject}),
+		ReadLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "read_latency_seconds",
+			Help:      "Time the messages wait in the buffer before being read out by Numaflow.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+		}, []string{LabelSubject}),
+		Disconnects: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "disconnects_total",
+			Help:      "Number of times the connection to NATS was lost.",
+		}),
+		Reconnects: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "reconnects_total",
+			Help:      "Number of times the connection to NATS was re-established.",
+		}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.MessagesReceived,
+		m.BytesReceived,
+		m.MessagesRead,
+		m.MessagesDropped,
+		m.MessagesDeadLettered,
+		m.ReadLatency,
+		m.Disconnects,
+		m.Reconnects,
+	)
+	return m
+}
+
+// RegisterBufferSize registers the gauge reporting the number of buffered messages, as returned by size.
+func (m *Metrics) RegisterBufferSize(size func() int) error {
+	return m.registry.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "buffered_messages",
+		Help:      "Number of messages received and waiting in the buffer to be read.",
+	}, func() float64 {
+		return float64(size())
+	}))
+}
+
+// Handler returns the HTTP handler serving the metrics.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// Serve serves the metrics on the given port until the context is cancelled.
+func (m *Metrics) Serve(ctx context.Context, port int, logger *zap.SugaredLogger) error {
+	mux := http.NewServeMux()
+	mux.Handle(Path, m.Handler())
+	logger.Infof("Serving metrics on port %d", port)
+	if err := utils.ListenAndServe(ctx, port, mux); err != nil {
+		return fmt.Errorf("failed to serve metrics, %w", err)
+	}
+	return nil
+}
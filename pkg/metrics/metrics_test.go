@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestMetrics_Serve(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	assert.NoError(t, l.Close())
+
+	m := New()
+	assert.NoError(t, m.RegisterBufferSize(func() int { return 3 }))
+	assert.Error(t, m.RegisterBufferSize(func() int { return 3 }))
+	m.MessagesReceived.WithLabelValues("test-subject").Add(2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error)
+	go func() {
+		served <- m.Serve(ctx, port, zap.NewNop().Sugar())
+	}()
+
+	var body string
+	assert.Eventually(t, func() bool {
+		resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d%s", port, Path))
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		body = string(b)
+		return err == nil && resp.StatusCode == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Contains(t, body, `nats_source_messages_received_total{subject="test-subject"} 2`)
+	assert.Contains(t, body, "nats_source_buffered_messages 3")
+	assert.Contains(t, body, "go_goroutines")
+
+	cancel()
+	select {
+	case err := <-served:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("metrics server did not shutdown")
+	}
+}
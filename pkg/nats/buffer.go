@@ -0,0 +1,56 @@
+package nats
+
+import (
+	"go.uber.org/zap"
+
+	"github.com/numaproj-contrib/nats-source-go/pkg/config"
+	"github.com/numaproj-contrib/nats-source-go/pkg/metrics"
+)
+
+// dropLogInterval is the number of dropped messages between two logs reporting the drops.
+const dropLogInterval = 1000
+
+// enqueue adds a message received by a core NATS subscription to the buffer according to the overflow policy.
+// It returns false if the message could not be added because stop is closed while waiting for room in the buffer.
+func (n *natsSource) enqueue(m *Message, stop <-chan struct{}) bool {
+	switch n.overflowPolicy {
+	case config.OverflowDropNewest:
+		select {
+		case n.messages <- m:
+		default:
+			n.drop(m)
+		}
+	case config.OverflowDropOldest:
+		for {
+			select {
+			case n.messages <- m:
+				return true
+			default:
+			}
+			select {
+			case oldest := <-n.messages:
+				n.drop(oldest)
+			default:
+			}
+		}
+	default:
+		select {
+		case n.messages <- m:
+		case <-stop:
+			return false
+		}
+	}
+	return true
+}
+
+// drop discards a message because the buffer is full.
+func (n *natsSource) drop(m *Message) {
+	n.logger.Debug("Dropping nats message", zap.String("id", m.id))
+	n.metrics.MessagesDropped.WithLabelValues(m.subject, metrics.ReasonBufferFull).Inc()
+	if dropped := n.dropped.Add(1); dropped%dropLogInterval == 1 {
+		n.logger.Warn("Buffer is full, dropping messages",
+			zap.Int("bufferSize", n.bufferSize),
+			zap.String("overflowPolicy", string(n.overflowPolicy)),
+			zap.Uint64("totalDropped", dropped))
+	}
+}
@@ -0,0 +1,137 @@
+package nats
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	natslib "github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+
+	"github.com/numaproj-contrib/nats-source-go/pkg/config"
+)
+
+const (
+	defaultReconnectWait = 3 * time.Second
+	// defaultMaxReconnects reconnects forever.
+	defaultMaxReconnects = -1
+
+	defaultConnectMaxAttempts    = 10
+	defaultConnectInitialBackoff = time.Second
+	defaultConnectMaxBackoff     = 30 * time.Second
+	defaultConnectJitter         = 0.2
+)
+
+// connectionOptions returns the client options of the connection config.
+func connectionOptions(c *config.Connection) []natslib.Option {
+	if c == nil {
+		c = &config.Connection{}
+	}
+	reconnectWait := defaultReconnectWait
+	maxReconnects := defaultMaxReconnects
+	if c.ReconnectWait != nil {
+		reconnectWait = c.ReconnectWait.Duration
+	}
+	if c.MaxReconnects != nil {
+		maxReconnects = *c.MaxReconnects
+	}
+	opts := []natslib.Option{natslib.ReconnectWait(reconnectWait), natslib.MaxReconnects(maxReconnects)}
+	if c.Name != "" {
+		opts = append(opts, natslib.Name(c.Name))
+	}
+	if c.PingInterval != nil {
+		opts = append(opts, natslib.PingInterval(c.PingInterval.Duration))
+	}
+	if c.MaxPingsOutstanding > 0 {
+		opts = append(opts, natslib.MaxPingsOutstanding(c.MaxPingsOutstanding))
+	}
+	if c.InboxPrefix != "" {
+		opts = append(opts, natslib.CustomInboxPrefix(c.InboxPrefix))
+	}
+	if c.ReconnectBufferSize != 0 {
+		opts = append(opts, natslib.ReconnectBufSize(c.ReconnectBufferSize))
+	}
+	return opts
+}
+
+// setPendingLimits applies the configured pending limits to a subscription, the client defaults are kept otherwise.
+func (n *natsSource) setPendingLimits(sub *natslib.Subscription) error {
+	if n.pendingMessagesLimit == 0 && n.pendingBytesLimit == 0 {
+		return nil
+	}
+	msgLimit, bytesLimit := natslib.DefaultSubPendingMsgsLimit, natslib.DefaultSubPendingBytesLimit
+	if n.pendingMessagesLimit != 0 {
+		msgLimit = n.pendingMessagesLimit
+	}
+	if n.pendingBytesLimit != 0 {
+		bytesLimit = n.pendingBytesLimit
+	}
+	return sub.SetPendingLimits(msgLimit, bytesLimit)
+}
+
+// connectRetry is the retry policy of the initial connection to NATS.
+type connectRetry struct {
+	// maxAttempts is the maximum number of connection attempts, a negative value retries forever.
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	jitter         float64
+}
+
+// newConnectRetry returns the retry policy of the config, which makes a single attempt if it is nil.
+func newConnectRetry(c *config.ConnectRetry) connectRetry {
+	if c == nil {
+		return connectRetry{maxAttempts: 1}
+	}
+	r := connectRetry{
+		maxAttempts:    defaultConnectMaxAttempts,
+		initialBackoff: defaultConnectInitialBackoff,
+		maxBackoff:     defaultConnectMaxBackoff,
+		jitter:         defaultConnectJitter,
+	}
+	if c.MaxAttempts != 0 {
+		r.maxAttempts = c.MaxAttempts
+	}
+	if c.InitialBackoff != nil {
+		r.initialBackoff = c.InitialBackoff.Duration
+	}
+	if c.MaxBackoff != nil {
+		r.maxBackoff = c.MaxBackoff.Duration
+	}
+	if c.Jitter != nil {
+		r.jitter = *c.Jitter
+	}
+	return r
+}
+
+// backoff returns the time to wait after the given failed attempt, starting from 1.
+func (r connectRetry) backoff(attempt int) time.Duration {
+	d := r.initialBackoff
+	for i := 1; i < attempt && d < r.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > r.maxBackoff {
+		d = r.maxBackoff
+	}
+	return d + time.Duration(rand.Float64()*r.jitter*float64(d))
+}
+
+// connect connects to the NATS server, retrying according to the retry policy.
+func (n *natsSource) connect(url string, retry connectRetry, opts ...natslib.Option) (*natslib.Conn, error) {
+	for attempt := 1; ; attempt++ {
+		conn, err := natslib.Connect(url, opts...)
+		if err == nil {
+			return conn, nil
+		}
+		if retry.maxAttempts >= 0 && attempt >= retry.maxAttempts {
+			if attempt > 1 {
+				return nil, fmt.Errorf("%w, after %d attempts", err, attempt)
+			}
+			return nil, err
+		}
+		backoff := retry.backoff(attempt)
+		n.logger.Warn("Failed to connect to nats server, retrying",
+			zap.Int("attempt", attempt), zap.Duration("backoff", backoff), zap.Error(err))
+		time.Sleep(backoff)
+	}
+}
@@ -0,0 +1,156 @@
+package nats
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	natslib "github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+
+	"github.com/numaproj-contrib/nats-source-go/pkg/config"
+)
+
+const (
+	// deadLetterSubjectHeader holds the subject of the original message.
+	deadLetterSubjectHeader = "Nats-Dead-Letter-Subject"
+	// deadLetterStreamHeader holds the stream of the original JetStream message.
+	deadLetterStreamHeader = "Nats-Dead-Letter-Stream"
+	// deadLetterSequenceHeader holds the stream sequence of the original JetStream message.
+	deadLetterSequenceHeader = "Nats-Dead-Letter-Sequence"
+	// deadLetterDeliveriesHeader holds the number of times the original JetStream message was delivered.
+	deadLetterDeliveriesHeader = "Nats-Dead-Letter-Deliveries"
+	// deadLetterReasonHeader holds why the original message could not be processed.
+	deadLetterReasonHeader = "Nats-Dead-Letter-Reason"
+
+	// maxDeliveriesAdvisory is the subject prefix of the advisories sent by the server when a message exceeds the
+	// maximum number of deliveries of a consumer, followed by the stream and consumer names.
+	maxDeliveriesAdvisory = "$JS.EVENT.ADVISORY.CONSUMER.MAX_DELIVERIES"
+	// maxDeliveriesQueue is the prefix of the queue group of the advisories, followed by the stream and consumer names,
+	// so that each advisory is handled by one of the sources sharing the consumer.
+	maxDeliveriesQueue = "nats-source-max-deliveries"
+	// maxDeliveriesReason is the dead-letter reason of the messages which exceed the maximum number of deliveries.
+	maxDeliveriesReason = "maximum number of deliveries exceeded"
+
+	// deadLetterRetryDelay delays the redelivery of the JetStream messages which could not be dead-lettered.
+	deadLetterRetryDelay = 5 * time.Second
+)
+
+// deadLetterPublisher republishes the messages which cannot be processed to the dead-letter subject.
+type deadLetterPublisher struct {
+	subject string
+	nc      *natslib.Conn
+	// js publishes the messages with JetStream when set, the connection publishes them with core NATS otherwise.
+	js natslib.JetStreamContext
+}
+
+func newDeadLetterPublisher(nc *natslib.Conn, c *config.DeadLetter) (*deadLetterPublisher, error) {
+	p := &deadLetterPublisher{subject: c.Subject, nc: nc}
+	if c.JetStream {
+		js, err := nc.JetStream()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get JetStream context, %w", err)
+		}
+		p.js = js
+	}
+	return p, nil
+}
+
+// jetStreamOrigin describes where a dead-lettered JetStream message comes from.
+type jetStreamOrigin struct {
+	stream     string
+	sequence   uint64
+	deliveries uint64
+}
+
+// publish republishes the payload and the headers of a message, along with the headers describing it.
+// The NATS headers of the original message are not copied, so that they don't apply to the republished message.
+func (p *deadLetterPublisher) publish(subject string, header natslib.Header, data []byte, origin *jetStreamOrigin, reason string) error {
+	msg := natslib.NewMsg(p.subject)
+	msg.Data = data
+	for k, v := range header {
+		if !strings.HasPrefix(k, "Nats-") {
+			msg.Header[k] = v
+		}
+	}
+	msg.Header.Set(deadLetterSubjectHeader, subject)
+	msg.Header.Set(deadLetterReasonHeader, reason)
+	if origin != nil {
+		msg.Header.Set(deadLetterStreamHeader, origin.stream)
+		msg.Header.Set(deadLetterSequenceHeader, strconv.FormatUint(origin.sequence, 10))
+		msg.Header.Set(deadLetterDeliveriesHeader, strconv.FormatUint(origin.deliveries, 10))
+	}
+	if p.js != nil {
+		_, err := p.js.PublishMsg(msg)
+		return err
+	}
+	return p.nc.PublishMsg(msg)
+}
+
+// deadLetter republishes a message received by the subscription to the given subject to the dead-letter subject.
+// It is a no-op when no dead-letter subject is configured.
+func (n *natsSource) deadLetter(msg *natslib.Msg, subject, reason string) error {
+	if n.deadLetters == nil {
+		return nil
+	}
+	var origin *jetStreamOrigin
+	if meta, err := msg.Metadata(); err == nil {
+		origin = &jetStreamOrigin{
+			stream:     meta.Stream,
+			sequence:   meta.Sequence.Stream,
+			deliveries: meta.NumDelivered,
+		}
+	}
+	if err := n.deadLetters.publish(msg.Subject, msg.Header, msg.Data, origin, reason); err != nil {
+		return fmt.Errorf("failed to publish to dead-letter subject %s, %w", n.deadLetters.subject, err)
+	}
+	n.metrics.MessagesDeadLettered.WithLabelValues(subject).Inc()
+	return nil
+}
+
+// terminate stops the redelivery of a JetStream message received by the subscription to the given subject, once it
+// is republished to the dead-letter subject if any. It is redelivered later instead when it can't be dead-lettered.
+func (n *natsSource) terminate(msg *natslib.Msg, subject, reason string) {
+	if err := n.deadLetter(msg, subject, reason); err != nil {
+		n.logger.Error("Failed to dead-letter JetStream message, redelivering it", zap.Error(err))
+		if err := msg.NakWithDelay(deadLetterRetryDelay); err != nil {
+			n.logger.Error("Failed to nak JetStream message", zap.Error(err))
+		}
+		return
+	}
+	if err := msg.Term(); err != nil {
+		n.logger.Error("Failed to terminate JetStream message", zap.Error(err))
+	}
+}
+
+// subscribeMaxDeliveries dead-letters the messages of a consumer which the server stops redelivering
+// because they exceed the maximum number of deliveries, the subject being the filter subject of the consumer.
+func (n *natsSource) subscribeMaxDeliveries(stream, consumer, subject string) (*natslib.Subscription, error) {
+	advisorySubject := fmt.Sprintf("%s.%s.%s", maxDeliveriesAdvisory, stream, consumer)
+	queue := fmt.Sprintf("%s.%s.%s", maxDeliveriesQueue, stream, consumer)
+	return n.natsConn.QueueSubscribe(advisorySubject, queue, func(msg *natslib.Msg) {
+		var advisory struct {
+			StreamSeq  uint64 `json:"stream_seq"`
+			Deliveries uint64 `json:"deliveries"`
+		}
+		if err := json.Unmarshal(msg.Data, &advisory); err != nil {
+			n.logger.Error("Failed to decode JetStream max deliveries advisory", zap.Error(err))
+			return
+		}
+		raw, err := n.js.GetMsg(stream, advisory.StreamSeq)
+		if err != nil {
+			n.logger.Error("Failed to get JetStream message exceeding max deliveries",
+				zap.Uint64("sequence", advisory.StreamSeq), zap.Error(err))
+			return
+		}
+		origin := &jetStreamOrigin{stream: stream, sequence: raw.Sequence, deliveries: advisory.Deliveries}
+		if err := n.deadLetters.publish(raw.Subject, raw.Header, raw.Data, origin, maxDeliveriesReason); err != nil {
+			n.logger.Error("Failed to dead-letter JetStream message exceeding max deliveries",
+				zap.Uint64("sequence", raw.Sequence), zap.Error(err))
+			return
+		}
+		n.metrics.MessagesDeadLettered.WithLabelValues(subject).Inc()
+	})
+}
@@ -0,0 +1,74 @@
+package nats
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	natslib "github.com/nats-io/nats.go"
+
+	"github.com/numaproj-contrib/nats-source-go/pkg/config"
+	"github.com/numaproj-contrib/nats-source-go/pkg/utils"
+)
+
+// eventTimeExtractor extracts the event time of a message from its metadata, headers or payload.
+type eventTimeExtractor struct {
+	config *config.EventTime
+}
+
+func newEventTimeExtractor(c *config.EventTime) *eventTimeExtractor {
+	if c == nil {
+		return nil
+	}
+	return &eventTimeExtractor{config: c}
+}
+
+// extract returns the event time of the message.
+func (e *eventTimeExtractor) extract(msg *natslib.Msg) (time.Time, error) {
+	switch e.config.Source {
+	case config.EventTimeFromPublishTime:
+		md, err := msg.Metadata()
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to get JetStream metadata, %w", err)
+		}
+		return md.Timestamp, nil
+	case config.EventTimeFromHeader:
+		v := msg.Header.Values(e.config.Header)
+		if len(v) == 0 {
+			return time.Time{}, fmt.Errorf("header %s not found", e.config.Header)
+		}
+		return e.parse(v[0])
+	case config.EventTimeFromPayload:
+		v, err := utils.GetJSONPathValue(msg.Data, e.config.JSONPath)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return e.parse(v)
+	default:
+		return time.Time{}, fmt.Errorf("unsupported event time source %q", e.config.Source)
+	}
+}
+
+func (e *eventTimeExtractor) parse(v string) (time.Time, error) {
+	switch e.config.Format {
+	case config.EventTimeFormatRFC3339, "":
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to parse event time %q as RFC3339, %w", v, err)
+		}
+		return t, nil
+	case config.EventTimeFormatEpochMillis:
+		ms, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to parse event time %q as epoch milliseconds, %w", v, err)
+		}
+		return time.UnixMilli(ms), nil
+	default:
+		return time.Time{}, fmt.Errorf("unsupported event time format %q", e.config.Format)
+	}
+}
+
+// drop returns whether a message whose event time cannot be extracted should be dropped.
+func (e *eventTimeExtractor) drop() bool {
+	return e.config.Fallback == config.EventTimeFallbackDrop
+}
@@ -0,0 +1,41 @@
+package nats
+
+import (
+	"fmt"
+	"time"
+
+	natslib "github.com/nats-io/nats.go"
+)
+
+// defaultDisconnectGracePeriod is how long the source can be disconnected from NATS before it is reported as not live.
+const defaultDisconnectGracePeriod = time.Minute
+
+// Live returns an error when the source has been disconnected from NATS for longer than the grace period,
+// e.g. because it is stuck reconnecting, or when the connection is closed.
+func (n *natsSource) Live() error {
+	if n.natsConn.IsClosed() {
+		return fmt.Errorf("nats connection is closed")
+	}
+	if since := n.disconnectedAt.Load(); since != 0 {
+		if d := time.Since(time.Unix(0, since)); d > n.disconnectGracePeriod {
+			return fmt.Errorf("nats disconnected for %s", d.Round(time.Second))
+		}
+	}
+	return nil
+}
+
+// Ready returns an error when the source cannot receive messages,
+// because it is not connected to NATS or one of its subscriptions is not valid.
+func (n *natsSource) Ready() error {
+	if status := n.natsConn.Status(); status != natslib.CONNECTED {
+		return fmt.Errorf("nats connection is %s", status)
+	}
+	n.subsMu.RLock()
+	defer n.subsMu.RUnlock()
+	for _, sub := range n.subs {
+		if !sub.IsValid() {
+			return fmt.Errorf("nats subscription to subject %s is not valid", sub.Subject)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package nats
+
+import (
+	"strings"
+
+	natslib "github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+
+	"github.com/numaproj-contrib/nats-source-go/pkg/config"
+	"github.com/numaproj-contrib/nats-source-go/pkg/utils"
+)
+
+// keyExtractor derives the keys of a message from its subject, headers and payload.
+type keyExtractor struct {
+	config *config.Keys
+	logger *zap.Logger
+}
+
+func newKeyExtractor(c *config.Keys, logger *zap.Logger) *keyExtractor {
+	if c == nil {
+		return nil
+	}
+	return &keyExtractor{config: c, logger: logger}
+}
+
+// extract returns the keys of the message, the sources which don't yield a value are skipped.
+func (e *keyExtractor) extract(msg *natslib.Msg) []string {
+	var keys []string
+	if len(e.config.SubjectTokens) > 0 {
+		tokens := strings.Split(msg.Subject, ".")
+		for _, position := range e.config.SubjectTokens {
+			if position < 1 || position > len(tokens) {
+				e.logger.Debug("Subject token not found", zap.String("subject", msg.Subject), zap.Int("position", position))
+				continue
+			}
+			keys = append(keys, tokens[position-1])
+		}
+	}
+	if e.config.Header != "" {
+		if v := msg.Header.Values(e.config.Header); len(v) > 0 {
+			keys = append(keys, v[0])
+		} else {
+			e.logger.Debug("Key header not found", zap.String("header", e.config.Header))
+		}
+	}
+	if e.config.JSONPath != "" {
+		if v, err := utils.GetJSONPathValue(msg.Data, e.config.JSONPath); err == nil {
+			keys = append(keys, v)
+		} else {
+			e.logger.Debug("Failed to extract key from payload", zap.String("path", e.config.JSONPath), zap.Error(err))
+		}
+	}
+	return keys
+}
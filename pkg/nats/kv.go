@@ -0,0 +1,137 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	natslib "github.com/nats-io/nats.go"
+
+	"github.com/numaproj-contrib/nats-source-go/pkg/config"
+)
+
+// kvInitTimeout is the maximum time to wait for the current entries of the keys to be skipped on start.
+const kvInitTimeout = 10 * time.Second
+
+var kvOperations = map[natslib.KeyValueOp]string{
+	natslib.KeyValuePut:    "PUT",
+	natslib.KeyValueDelete: "DEL",
+	natslib.KeyValuePurge:  "PURGE",
+}
+
+// kvEnvelope is the payload of a KV entry when the envelope is enabled.
+type kvEnvelope struct {
+	Key       string `json:"key"`
+	Operation string `json:"operation"`
+	Revision  uint64 `json:"revision"`
+	Value     []byte `json:"value,omitempty"`
+}
+
+// watchKeyValue watches the keys of the configured KV bucket, reading their updates into the message buffer.
+func (n *natsSource) watchKeyValue(c *config.Config) error {
+	js, err := n.natsConn.JetStream()
+	if err != nil {
+		return fmt.Errorf("failed to get JetStream context, %w", err)
+	}
+	kv, err := js.KeyValue(c.KeyValue.Bucket)
+	if err != nil {
+		return fmt.Errorf("failed to get KV bucket %s, %w", c.KeyValue.Bucket, err)
+	}
+	keys := c.KeyValue.Keys
+	if keys == "" {
+		keys = natslib.AllKeys
+	}
+	n.logger.Info(fmt.Sprintf("Watching keys %s of KV bucket %s", keys, c.KeyValue.Bucket))
+	w, err := kv.Watch(keys)
+	if err != nil {
+		return fmt.Errorf("failed to watch KV bucket %s, %w", c.KeyValue.Bucket, err)
+	}
+	n.kvWatcher = w
+	n.kvEnvelope = c.KeyValue.Envelope
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n.cancel = cancel
+	initialized := make(chan struct{})
+	n.wg.Add(1)
+	// The watch subscribes to the subjects of the keys in the stream backing the bucket.
+	go n.readKeyValue(ctx, w, fmt.Sprintf("$KV.%s.%s", c.KeyValue.Bucket, keys), c.KeyValue.InitialSnapshot, initialized)
+	if !c.KeyValue.InitialSnapshot {
+		// Make sure the current entries are skipped, so that no update made from now on is missed.
+		// The snapshot is not waited for, it may not fit in the buffer before the messages are read.
+		select {
+		case <-initialized:
+		case <-time.After(kvInitTimeout):
+			n.logger.Warn("Timed out waiting for the current KV entries, the next updates may be skipped")
+		}
+	}
+	return nil
+}
+
+// readKeyValue reads the entries of a KV watcher into the message buffer until the context is cancelled.
+// The subject is the subject of the watched keys. The watcher starts with the current entries of the keys,
+// which are skipped unless snapshot is true, followed by a nil entry and the updates. initialized is closed once the
+// current entries are read.
+func (n *natsSource) readKeyValue(ctx context.Context, w natslib.KeyWatcher, subject string, snapshot bool,
+	initialized chan<- struct{}) {
+	defer n.wg.Done()
+	updating := false
+	for {
+		var entry natslib.KeyValueEntry
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-w.Updates():
+			if !ok {
+				n.logger.Warn("KV watcher stopped")
+				return
+			}
+			entry = e
+		}
+		if entry == nil {
+			updating = true
+			close(initialized)
+			continue
+		}
+		// The deleted keys are not part of the current state of the bucket.
+		if !updating && (!snapshot || entry.Operation() != natslib.KeyValuePut) {
+			continue
+		}
+		// Like JetStream, the updates are never dropped, the watch waits for room in the buffer whatever the overflow policy.
+		m := n.newKeyValueMessage(entry, subject)
+		select {
+		case <-ctx.Done():
+			return
+		case n.messages <- m:
+		}
+	}
+}
+
+// newKeyValueMessage converts a KV entry read from the watch of the given subject to a Message.
+func (n *natsSource) newKeyValueMessage(entry natslib.KeyValueEntry, subject string) *Message {
+	n.metrics.MessagesReceived.WithLabelValues(subject).Inc()
+	n.metrics.BytesReceived.WithLabelValues(subject).Add(float64(len(entry.Value())))
+	// The revision is the sequence of the entry in the stream backing the bucket, which makes it a unique offset.
+	revision := strconv.FormatUint(entry.Revision(), 10)
+	payload := entry.Value()
+	if n.kvEnvelope {
+		// The envelope only holds strings, a number and bytes, which always marshal.
+		payload, _ = json.Marshal(kvEnvelope{
+			Key:       entry.Key(),
+			Operation: kvOperations[entry.Operation()],
+			Revision:  entry.Revision(),
+			Value:     entry.Value(),
+		})
+	}
+	return &Message{
+		payload:    payload,
+		readOffset: revision,
+		id:         revision,
+		keys:       []string{entry.Key()},
+		eventTime:  entry.Created(),
+		subject:    subject,
+		received:   time.Now(),
+		partition:  n.partitions[0],
+	}
+}
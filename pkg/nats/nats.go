@@ -2,38 +2,115 @@ package nats
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	natslib "github.com/nats-io/nats.go"
 	sourcesdk "github.com/numaproj/numaflow-go/pkg/sourcer"
 	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
 
 	"github.com/numaproj-contrib/nats-source-go/pkg/config"
+	"github.com/numaproj-contrib/nats-source-go/pkg/metrics"
 	"github.com/numaproj-contrib/nats-source-go/pkg/utils"
 )
 
 const (
 	defaultBufferSize = 1000
+	// defaultFetchBatchSize is the maximum number of messages requested by a single JetStream pull.
+	defaultFetchBatchSize = 100
+	// defaultReadLinger is how long a read waits for more messages once it has read at least one.
+	defaultReadLinger = 50 * time.Millisecond
+	// defaultDrainTimeout is the maximum time to wait for the buffered messages to be read on shutdown,
+	// well below the default termination grace period of 30s of the pods.
+	defaultDrainTimeout = 10 * time.Second
+	// closeNakDelay delays the redelivery of the JetStream messages discarded on shutdown. The pending pull requests
+	// of the closing connection would otherwise get them back right away, and lose them until AckWait elapses.
+	closeNakDelay = time.Second
 )
 
 type Message struct {
-	payload    string
+	// payload is the one of the original NATS message, it is never copied.
+	payload    []byte
 	readOffset string
 	id         string
+	keys       []string
+	// eventTime is the extracted event time, the zero value means the time the message is read.
+	eventTime time.Time
+	// msg is the original JetStream message, it is nil for core NATS messages and KV entries.
+	msg *natslib.Msg
+	// subject is the subject of the subscription which received the message, used to label the metrics.
+	subject string
+	// received is the time the message was received.
+	received time.Time
+	// partition is the partition of the subscription which received the message.
+	partition int32
 }
 
 type natsSource struct {
 	natsConn *natslib.Conn
-	sub      *natslib.Subscription
+	subs     []*natslib.Subscription
+	// subsMu guards subs, which are cleared by Close while the probes, Pending or Drain may read them.
+	subsMu sync.RWMutex
+	js     natslib.JetStreamContext
+	// partitions are the partitions of the source, each subscription belongs to one of them.
+	partitions []int32
 
-	bufferSize int
-	messages   chan *Message
+	// kvWatcher watches the keys of the KV bucket in KV mode.
+	kvWatcher natslib.KeyWatcher
+	// kvEnvelope wraps the KV entries in a JSON object describing them.
+	kvEnvelope bool
 
-	volumeReader utils.VolumeReader
+	// cancel stops the JetStream fetch loop or the KV watch loop, wg waits for them to exit.
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
 
-	logger *zap.Logger
+	// inflight holds the JetStream messages which have been read but not acknowledged yet, keyed by read offset.
+	inflight   map[string]inflightMessage
+	inflightMu sync.Mutex
+	// redelivery releases the in-flight messages which are not acknowledged within the ack deadline.
+	redelivery redeliveryPolicy
+
+	// deadLetters republishes the messages which cannot be processed, nil when they are dropped.
+	deadLetters *deadLetterPublisher
+	// advisories are the subscriptions to the max deliveries advisories of the JetStream consumers.
+	advisories []*natslib.Subscription
+
+	bufferSize     int
+	overflowPolicy config.OverflowPolicy
+	messages       chan *Message
+	// readLinger is how long a read waits for more messages once it has read at least one.
+	readLinger time.Duration
+	// dropped is the number of messages dropped because the buffer was full.
+	dropped atomic.Uint64
+	// done is closed on shutdown to release the subscription callbacks blocked on a full buffer.
+	done chan struct{}
+
+	// drainTimeout is the maximum time to wait for the buffered messages to be read on shutdown,
+	// 0 means the buffered messages are dropped right away.
+	drainTimeout time.Duration
+
+	volumeReader  utils.VolumeReader
+	secretWatcher *utils.SecretWatcher
+
+	keyExtractor       *keyExtractor
+	eventTimeExtractor *eventTimeExtractor
+
+	// disconnectedAt is the time in nanoseconds since the connection to NATS was lost, 0 while connected.
+	disconnectedAt        atomic.Int64
+	disconnectGracePeriod time.Duration
+
+	// pendingMessagesLimit and pendingBytesLimit are the pending limits of the subscriptions, 0 keeps the default.
+	pendingMessagesLimit int
+	pendingBytesLimit    int
+
+	metrics *metrics.Metrics
+	logger  *zap.Logger
 }
 
 type Option func(*natsSource) error
@@ -46,9 +123,43 @@ func WithLogger(l *zap.Logger) Option {
 	}
 }
 
-func New(c *config.Config, opts ...Option) (*natsSource, error) {
+// WithMetrics is used to record the metrics of the source, which are not exposed otherwise
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(o *natsSource) error {
+		o.metrics = m
+		return nil
+	}
+}
+
+// WithVolumeReader is used to read the secrets from a custom location
+func WithVolumeReader(r utils.VolumeReader) Option {
+	return func(o *natsSource) error {
+		o.volumeReader = r
+		return nil
+	}
+}
+
+func New(c *config.Config, opts ...Option) (_ *natsSource, err error) {
 	n := &natsSource{
-		bufferSize: defaultBufferSize,
+		bufferSize:            defaultBufferSize,
+		readLinger:            defaultReadLinger,
+		overflowPolicy:        config.OverflowBlock,
+		disconnectGracePeriod: defaultDisconnectGracePeriod,
+	}
+	if c.Buffer != nil {
+		if c.Buffer.Size > 0 {
+			n.bufferSize = c.Buffer.Size
+		}
+		if c.Buffer.OverflowPolicy != "" {
+			n.overflowPolicy = c.Buffer.OverflowPolicy
+		}
+	}
+	if c.Connection != nil {
+		n.pendingMessagesLimit = c.Connection.PendingMessagesLimit
+		n.pendingBytesLimit = c.Connection.PendingBytesLimit
+	}
+	if c.Health != nil && c.Health.DisconnectGracePeriod != nil {
+		n.disconnectGracePeriod = c.Health.DisconnectGracePeriod.Duration
 	}
 	for _, o := range opts {
 		if err := o(n); err != nil {
@@ -56,7 +167,6 @@ func New(c *config.Config, opts ...Option) (*natsSource, error) {
 		}
 	}
 	if n.logger == nil {
-		var err error
 		n.logger, err = zap.NewDevelopment()
 		if err != nil {
 			return nil, fmt.Errorf("failed to create logger, %w", err)
@@ -64,24 +174,71 @@ func New(c *config.Config, opts ...Option) (*natsSource, error) {
 	}
 
 	n.messages = make(chan *Message, n.bufferSize)
-	n.volumeReader = utils.NewNatsVolumeReader(utils.SecretVolumePath)
+	if n.metrics == nil {
+		n.metrics = metrics.New()
+	}
+	if err := n.metrics.RegisterBufferSize(func() int { return len(n.messages) }); err != nil {
+		return nil, fmt.Errorf("failed to register buffer metrics, %w", err)
+	}
+	n.done = make(chan struct{})
+	if c.Drain != nil {
+		n.drainTimeout = defaultDrainTimeout
+		if c.Drain.Timeout != nil {
+			n.drainTimeout = c.Drain.Timeout.Duration
+		}
+	}
+	n.partitions = partitionIDs(c)
+	n.keyExtractor = newKeyExtractor(c.Keys, n.logger)
+	n.eventTimeExtractor = newEventTimeExtractor(c.EventTime)
+	if n.volumeReader == nil {
+		n.volumeReader = utils.NewNatsVolumeReader(utils.SecretVolumePath)
+	}
 
-	opt := []natslib.Option{
-		natslib.MaxReconnects(-1),
-		natslib.ReconnectWait(3 * time.Second),
+	opt := append(connectionOptions(c.Connection),
 		natslib.DisconnectHandler(func(c *natslib.Conn) {
 			n.logger.Info("NATS disconnected")
+			n.metrics.Disconnects.Inc()
+			n.disconnectedAt.CompareAndSwap(0, time.Now().UnixNano())
 		}),
 		natslib.ReconnectHandler(func(c *natslib.Conn) {
 			n.logger.Info("NATS reconnected")
+			n.metrics.Reconnects.Inc()
+			n.disconnectedAt.Store(0)
 		}),
+	)
+
+	if c.TLS != nil || c.Auth != nil {
+		// Watch the secrets, so that the rotated ones are used by the next reconnect.
+		if w, err := utils.NewSecretWatcher(n.logger); err != nil {
+			n.logger.Warn("Failed to watch secrets, rotated secrets require a restart", zap.Error(err))
+		} else {
+			n.secretWatcher = w
+			defer func() {
+				if err != nil {
+					_ = w.Close()
+				}
+			}()
+		}
 	}
 
 	if c.TLS != nil {
-		if c, err := utils.GetTLSConfig(c.TLS, n.volumeReader); err != nil {
+		tlsConfig, err := utils.GetTLSConfig(c.TLS, n.volumeReader)
+		if err != nil {
 			return nil, err
-		} else {
-			opt = append(opt, natslib.Secure(c))
+		}
+		if n.secretWatcher != nil {
+			if err := utils.WatchClientCertificate(tlsConfig, c.TLS, n.volumeReader, n.secretWatcher); err != nil {
+				return nil, fmt.Errorf("failed to watch client cert, %w", err)
+			}
+		}
+		opt = append(opt, natslib.Secure(tlsConfig))
+		if c.TLS.CACertSecret != nil {
+			// The CA cert is read again on every connection, so that a rotated one is used by the next reconnect.
+			caCertPath, err := n.volumeReader.GetSecretVolumePath(c.TLS.CACertSecret)
+			if err != nil {
+				return nil, err
+			}
+			opt = append(opt, natslib.RootCAs(caCertPath))
 		}
 	}
 
@@ -97,12 +254,29 @@ func New(c *config.Config, opts ...Option) (*natsSource, error) {
 				return nil, fmt.Errorf("failed to get basic auth password, %w", err)
 			}
 			opt = append(opt, natslib.UserInfo(username, password))
+			// The client has no callback for the user and password, they can't be refreshed on reconnect.
+			for _, selector := range []*corev1.SecretKeySelector{c.Auth.Basic.User, c.Auth.Basic.Password} {
+				if err := n.watchSecret(selector, func([]byte) {
+					n.logger.Warn("Basic auth secret rotated, restart the source to use it")
+				}); err != nil {
+					return nil, fmt.Errorf("failed to watch basic auth secret, %w", err)
+				}
+			}
 		case c.Auth.Token != nil:
 			token, err := n.volumeReader.GetSecretFromVolume(c.Auth.Token)
 			if err != nil {
 				return nil, fmt.Errorf("failed to get auth token, %w", err)
 			}
-			opt = append(opt, natslib.Token(token))
+			var current atomic.Value
+			current.Store(token)
+			if err := n.watchSecret(c.Auth.Token, func(data []byte) {
+				current.Store(strings.TrimSuffix(string(data), "\n"))
+			}); err != nil {
+				return nil, fmt.Errorf("failed to watch auth token, %w", err)
+			}
+			opt = append(opt, natslib.TokenHandler(func() string {
+				return current.Load().(string)
+			}))
 		case c.Auth.NKey != nil:
 			nKeyFile, err := n.volumeReader.GetSecretVolumePath(c.Auth.NKey)
 			if err != nil {
@@ -113,79 +287,539 @@ func New(c *config.Config, opts ...Option) (*natsSource, error) {
 				return nil, fmt.Errorf("failed to get NKey, %w", err)
 			}
 			opt = append(opt, o)
+			// The public key is sent along with the signature, a rotated seed can't be used on reconnect.
+			if err := n.watchSecret(c.Auth.NKey, func([]byte) {
+				n.logger.Warn("NKey secret rotated, restart the source to use it")
+			}); err != nil {
+				return nil, fmt.Errorf("failed to watch nkey, %w", err)
+			}
+		// The credentials files are read on every connection, the rotated ones are used by the next reconnect.
+		case c.Auth.Credentials != nil:
+			credsFile, err := n.volumeReader.GetSecretVolumePath(c.Auth.Credentials)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get configured credentials file, %w", err)
+			}
+			opt = append(opt, natslib.UserCredentials(credsFile))
+		case c.Auth.JWT != nil && c.Auth.JWT.JWT != nil && c.Auth.JWT.Seed != nil:
+			jwtFile, err := n.volumeReader.GetSecretVolumePath(c.Auth.JWT.JWT)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get configured jwt file, %w", err)
+			}
+			seedFile, err := n.volumeReader.GetSecretVolumePath(c.Auth.JWT.Seed)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get configured seed file, %w", err)
+			}
+			opt = append(opt, natslib.UserCredentials(jwtFile, seedFile))
 		}
 	}
 
 	n.logger.Info("Connecting to nats service...")
-	if conn, err := natslib.Connect(c.URL, opt...); err != nil {
+	if conn, err := n.connect(c.URL, newConnectRetry(c.ConnectRetry), opt...); err != nil {
 		n.logger.Error("Failed to connect to nats server", zap.Error(err))
 		return nil, fmt.Errorf("failed to connect to nats server, %w", err)
 	} else {
 		n.natsConn = conn
 	}
 
-	n.logger.Info(fmt.Sprintf("Subscribing to subject %s with queue %s", c.Subject, c.Queue))
-	if sub, err := n.natsConn.QueueSubscribe(c.Subject, c.Queue, func(msg *natslib.Msg) {
-		readOffset := uuid.New().String()
-		m := &Message{
-			payload:    string(msg.Data),
-			readOffset: readOffset,
-			id:         readOffset,
+	if c.DeadLetter != nil {
+		if n.deadLetters, err = newDeadLetterPublisher(n.natsConn, c.DeadLetter); err != nil {
+			n.natsConn.Close()
+			return nil, err
+		}
+	}
+
+	switch {
+	case c.KeyValue != nil:
+		if err := n.watchKeyValue(c); err != nil {
+			n.natsConn.Close()
+			return nil, err
+		}
+	case c.JetStream != nil:
+		if err := n.pullSubscribe(c); err != nil {
+			n.natsConn.Close()
+			return nil, err
+		}
+	default:
+		if err := n.queueSubscribe(c); err != nil {
+			n.natsConn.Close()
+			return nil, err
 		}
-		n.messages <- m
-	}); err != nil {
-		n.logger.Error("Failed to QueueSubscribe nats messages", zap.Error(err))
-		n.natsConn.Close()
-		return nil, fmt.Errorf("failed to QueueSubscribe nats messages, %w", err)
-	} else {
-		n.sub = sub
 	}
 	n.logger.Info("NATS source server started")
 	return n, nil
 }
 
+// watchSecret calls onChange with the new value of the secret whenever it is rotated.
+func (n *natsSource) watchSecret(selector *corev1.SecretKeySelector, onChange func([]byte)) error {
+	if n.secretWatcher == nil {
+		return nil
+	}
+	path, err := n.volumeReader.GetSecretVolumePath(selector)
+	if err != nil {
+		return err
+	}
+	return n.secretWatcher.Watch(path, onChange)
+}
+
+// queueSubscribe subscribes to the configured subjects with core NATS queue subscriptions.
+func (n *natsSource) queueSubscribe(c *config.Config) error {
+	subscriptions := partitionSubscriptions(c)
+	if len(subscriptions) == 0 {
+		return fmt.Errorf("no subject to subscribe to")
+	}
+	for _, s := range subscriptions {
+		n.logger.Info(fmt.Sprintf("Subscribing to subject %s with queue %s for partition %d", s.Subject, s.Queue, s.partition))
+		subject, partition := s.Subject, s.partition
+		sub, err := n.natsConn.QueueSubscribe(subject, s.Queue, func(msg *natslib.Msg) {
+			m, err := n.newMessage(msg, subject, uuid.New().String())
+			if err != nil {
+				n.logger.Warn("Dropping nats message", zap.String("subject", msg.Subject), zap.Error(err))
+				if err := n.deadLetter(msg, subject, err.Error()); err != nil {
+					n.logger.Error("Failed to dead-letter nats message", zap.Error(err))
+				}
+				return
+			}
+			m.partition = partition
+			n.enqueue(m, n.done)
+		})
+		if err != nil {
+			n.logger.Error("Failed to QueueSubscribe nats messages", zap.String("subject", s.Subject), zap.Error(err))
+			n.unsubscribe()
+			return fmt.Errorf("failed to QueueSubscribe nats messages on subject %s, %w", s.Subject, err)
+		}
+		n.subs = append(n.subs, sub)
+		if err := n.setPendingLimits(sub); err != nil {
+			n.unsubscribe()
+			return fmt.Errorf("failed to set pending limits on subject %s, %w", s.Subject, err)
+		}
+	}
+	// Make sure the server has processed the subscriptions, so that no message published from now on is missed.
+	if err := n.natsConn.Flush(); err != nil {
+		n.logger.Error("Failed to flush nats subscriptions", zap.Error(err))
+		n.unsubscribe()
+		return fmt.Errorf("failed to flush nats subscriptions, %w", err)
+	}
+	return nil
+}
+
+// pullSubscribe binds to the configured JetStream durable pull consumers, one per partition, creating them if they
+// do not exist, and starts fetching messages from them in the background.
+func (n *natsSource) pullSubscribe(c *config.Config) error {
+	js, err := n.natsConn.JetStream()
+	if err != nil {
+		n.logger.Error("Failed to get JetStream context", zap.Error(err))
+		return fmt.Errorf("failed to get JetStream context, %w", err)
+	}
+	n.js = js
+
+	subOpts := []natslib.SubOpt{
+		natslib.BindStream(c.JetStream.Stream),
+		natslib.AckExplicit(),
+	}
+	if c.JetStream.AckWait != nil {
+		subOpts = append(subOpts, natslib.AckWait(c.JetStream.AckWait.Duration))
+	}
+	// Without a deliver policy, an existing consumer is bound whatever its policy, and a new one delivers all the messages.
+	switch c.JetStream.DeliverPolicy {
+	case "":
+	case config.DeliverAll:
+		subOpts = append(subOpts, natslib.DeliverAll())
+	case config.DeliverLast:
+		subOpts = append(subOpts, natslib.DeliverLast())
+	case config.DeliverNew:
+		subOpts = append(subOpts, natslib.DeliverNew())
+	case config.DeliverByStartSequence:
+		subOpts = append(subOpts, natslib.StartSequence(c.JetStream.StartSequence))
+	case config.DeliverByStartTime:
+		if c.JetStream.StartTime == nil {
+			return fmt.Errorf("a start time is required with JetStream deliver policy %q", c.JetStream.DeliverPolicy)
+		}
+		subOpts = append(subOpts, natslib.StartTime(c.JetStream.StartTime.Time))
+	case config.DeliverLastPerSubject:
+		subOpts = append(subOpts, natslib.DeliverLastPerSubject())
+	default:
+		return fmt.Errorf("unsupported JetStream deliver policy %q", c.JetStream.DeliverPolicy)
+	}
+	n.redelivery = newRedeliveryPolicy(c.JetStream)
+	subOpts = append(subOpts, n.redelivery.subOpts()...)
+
+	subscriptions := partitionSubscriptions(c)
+	if c.Provision != nil {
+		if err := n.provision(c, subscriptions); err != nil {
+			n.logger.Error("Failed to provision JetStream", zap.Error(err))
+			return fmt.Errorf("failed to provision JetStream, %w", err)
+		}
+	}
+	for _, s := range subscriptions {
+		subject, opts := s.Subject, subOpts
+		// In dry-run mode, the consumer is bound as it is, without being created or checked against the config.
+		if c.Provision != nil && c.Provision.DryRun {
+			info, err := js.ConsumerInfo(c.JetStream.Stream, s.consumer)
+			if err != nil {
+				n.logger.Error("Failed to get JetStream consumer", zap.String("consumer", s.consumer), zap.Error(err))
+				return fmt.Errorf("consumer %s must exist to be bound in dry-run mode, %w", s.consumer, err)
+			}
+			subject, opts = info.Config.FilterSubject, []natslib.SubOpt{natslib.Bind(c.JetStream.Stream, s.consumer)}
+		}
+		n.logger.Info(fmt.Sprintf("Binding to JetStream stream %s with durable consumer %s on subject %s for partition %d",
+			c.JetStream.Stream, s.consumer, s.Subject, s.partition))
+		sub, err := js.PullSubscribe(subject, s.consumer, opts...)
+		if err != nil {
+			n.logger.Error("Failed to PullSubscribe JetStream messages", zap.String("consumer", s.consumer), zap.Error(err))
+			return fmt.Errorf("failed to PullSubscribe JetStream messages with consumer %s, %w", s.consumer, err)
+		}
+		n.subs = append(n.subs, sub)
+		if err := n.setPendingLimits(sub); err != nil {
+			return fmt.Errorf("failed to set pending limits, %w", err)
+		}
+		if n.deadLetters != nil && n.redelivery.maxDeliver > 0 {
+			advisory, err := n.subscribeMaxDeliveries(c.JetStream.Stream, s.consumer, s.Subject)
+			if err != nil {
+				return fmt.Errorf("failed to subscribe to the max deliveries advisories of consumer %s, %w", s.consumer, err)
+			}
+			n.advisories = append(n.advisories, advisory)
+		}
+	}
+	n.inflight = make(map[string]inflightMessage)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n.cancel = cancel
+	for i, sub := range n.subs {
+		n.wg.Add(1)
+		go n.fetch(ctx, sub, subscriptions[i].Subject, subscriptions[i].partition)
+	}
+	if n.redelivery.ackDeadline > 0 {
+		n.wg.Add(1)
+		go n.expireInflight(ctx)
+	}
+	return nil
+}
+
+// fetch keeps pulling messages from a JetStream consumer into the message buffer until the context is cancelled.
+// The subject is the filter subject of the consumer, and the messages belong to the given partition.
+func (n *natsSource) fetch(ctx context.Context, sub *natslib.Subscription, subject string, partition int32) {
+	defer n.wg.Done()
+	for {
+		// Only pull as many messages as the buffer can hold, so that the buffer never overflows.
+		batch := defaultFetchBatchSize
+		if room := n.bufferSize - len(n.messages); room < batch {
+			batch = room
+		}
+		if batch == 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(100 * time.Millisecond):
+			}
+			continue
+		}
+		msgs, err := sub.Fetch(batch, natslib.Context(ctx))
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil && !errors.Is(err, natslib.ErrTimeout) && !errors.Is(err, context.DeadlineExceeded) {
+			n.logger.Error("Failed to fetch JetStream messages", zap.Error(err))
+			// Back off a little to avoid a busy loop while the server is unavailable.
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		for _, msg := range msgs {
+			// The reply subject encodes the stream and consumer sequences of the delivery,
+			// which makes it a unique offset that can be acknowledged.
+			m, err := n.newMessage(msg, subject, msg.Reply)
+			if err != nil {
+				// Terminate the message, it would fail the same way if it was redelivered.
+				n.logger.Warn("Dropping JetStream message", zap.String("subject", msg.Subject), zap.Error(err))
+				n.terminate(msg, subject, err.Error())
+				continue
+			}
+			m.msg = msg
+			m.partition = partition
+			select {
+			case <-ctx.Done():
+				return
+			case n.messages <- m:
+			}
+		}
+	}
+}
+
+// newMessage converts a NATS message received by the subscription to the given subject to a Message
+// with the given read offset. An error is returned when the message has to be dropped.
+func (n *natsSource) newMessage(msg *natslib.Msg, subject, readOffset string) (*Message, error) {
+	n.metrics.MessagesReceived.WithLabelValues(subject).Inc()
+	n.metrics.BytesReceived.WithLabelValues(subject).Add(float64(len(msg.Data)))
+	m := &Message{
+		payload:    msg.Data,
+		readOffset: readOffset,
+		id:         readOffset,
+		subject:    subject,
+		received:   time.Now(),
+	}
+	if n.keyExtractor != nil {
+		m.keys = n.keyExtractor.extract(msg)
+	}
+	if n.eventTimeExtractor != nil {
+		eventTime, err := n.eventTimeExtractor.extract(msg)
+		if err != nil {
+			if n.eventTimeExtractor.drop() {
+				n.metrics.MessagesDropped.WithLabelValues(subject, metrics.ReasonInvalid).Inc()
+				return nil, fmt.Errorf("failed to extract event time, %w", err)
+			}
+			n.logger.Debug("Failed to extract event time, falling back to the read time", zap.Error(err))
+		}
+		m.eventTime = eventTime
+	}
+	return m, nil
+}
+
 // Pending returns the number of pending records.
+// It is the number of messages buffered locally plus the ones not delivered to the source yet, which is
+// the subscription pending count for core NATS and the consumer pending count for JetStream.
+// In KV mode, only the buffered messages are counted. -1 is returned when the pending information is not available.
 func (n *natsSource) Pending(_ context.Context) int64 {
-	// Pending is not supported for NATS for now, returning -1 to indicate pending is not available.
-	return -1
+	pending := int64(len(n.messages))
+	n.subsMu.RLock()
+	defer n.subsMu.RUnlock()
+	for _, sub := range n.subs {
+		if n.js != nil {
+			info, err := sub.ConsumerInfo()
+			if err != nil {
+				n.logger.Error("Failed to get JetStream consumer info", zap.Error(err))
+				return -1
+			}
+			pending += int64(info.NumPending)
+			continue
+		}
+		msgs, _, err := sub.Pending()
+		if err != nil {
+			n.logger.Error("Failed to get subscription pending messages", zap.Error(err))
+			return -1
+		}
+		pending += int64(msgs)
+	}
+	return pending
 }
 
-func (n *natsSource) Read(_ context.Context, readRequest sourcesdk.ReadRequest, messageCh chan<- sourcesdk.Message) {
+// Read reads up to the requested number of messages from the buffer.
+// It returns once the read request times out or the context is cancelled, and otherwise shortly after the first
+// message is read, so that the messages which are available are not held back until the timeout.
+func (n *natsSource) Read(ctx context.Context, readRequest sourcesdk.ReadRequest, messageCh chan<- sourcesdk.Message) {
 	// Handle the timeout specification in the read request.
-	ctx, cancel := context.WithTimeout(context.Background(), readRequest.TimeOut())
+	ctx, cancel := context.WithTimeout(ctx, readRequest.TimeOut())
 	defer cancel()
 
-	// Read the data from the source and send the data to the message channel.
+	// linger is nil, which blocks forever, until the first message is read.
+	var linger <-chan time.Time
 	for i := 0; uint64(i) < readRequest.Count(); i++ {
+		if ctx.Err() != nil {
+			return
+		}
 		select {
 		case <-ctx.Done():
-			// If the context is done, the read request is timed out.
+			// If the context is done, the read request is cancelled or timed out.
+			return
+		case <-linger:
 			return
 		case m := <-n.messages:
 			// Otherwise, we read the data from the source and send the data to the message channel.
-			messageCh <- sourcesdk.NewMessage(
-				[]byte(m.payload),
-				sourcesdk.NewOffsetWithDefaultPartitionId([]byte(m.readOffset)),
-				time.Now())
+			messageCh <- n.toSourceMessage(m)
+			if linger == nil {
+				timer := time.NewTimer(n.readLinger)
+				defer timer.Stop()
+				linger = timer.C
+			}
 		}
 	}
 }
 
-func (n *natsSource) Partitions(ctx context.Context) []int32 {
-	return sourcesdk.DefaultPartitions()
+// toSourceMessage converts a buffered message to the message sent to Numaflow.
+func (n *natsSource) toSourceMessage(m *Message) sourcesdk.Message {
+	if m.msg != nil {
+		// Track the JetStream message until Numaflow acknowledges its offset.
+		n.inflightMu.Lock()
+		n.inflight[m.readOffset] = inflightMessage{msg: m.msg, subject: m.subject, readAt: time.Now()}
+		n.inflightMu.Unlock()
+	}
+	n.metrics.MessagesRead.WithLabelValues(m.subject).Inc()
+	n.metrics.ReadLatency.WithLabelValues(m.subject).Observe(time.Since(m.received).Seconds())
+	eventTime := m.eventTime
+	if eventTime.IsZero() {
+		eventTime = time.Now()
+	}
+	return sourcesdk.NewMessage(
+		m.payload,
+		sourcesdk.NewOffset([]byte(m.readOffset), m.partition),
+		eventTime).WithKeys(m.keys)
+}
+
+// Partitions returns the partitions of the source, which is the default partition unless partitions are configured.
+func (n *natsSource) Partitions(_ context.Context) []int32 {
+	return n.partitions
 }
 
 // Ack acknowledges the data from the source.
+// For JetStream, the messages matching the offsets are acknowledged, the ones never acknowledged are redelivered
+// once the ack deadline, or AckWait when it is not set, elapses. Ack is a no-op for core NATS and KV.
 func (n *natsSource) Ack(_ context.Context, request sourcesdk.AckRequest) {
-	// Ack is a no-op for the NATS source.
+	if n.js == nil {
+		return
+	}
+	for _, offset := range request.Offsets() {
+		n.inflightMu.Lock()
+		m, ok := n.inflight[string(offset.Value())]
+		delete(n.inflight, string(offset.Value()))
+		n.inflightMu.Unlock()
+		if !ok {
+			n.logger.Warn("No in-flight JetStream message found for offset", zap.ByteString("offset", offset.Value()))
+			continue
+		}
+		if err := m.msg.Ack(); err != nil {
+			n.logger.Error("Failed to ack JetStream message", zap.ByteString("offset", offset.Value()), zap.Error(err))
+		}
+	}
 }
 
 func (n *natsSource) Close() error {
 	n.logger.Info("Shutting down nats source server...")
-	if err := n.sub.Unsubscribe(); err != nil {
-		n.logger.Error("Failed to unsubscribe nats subscription", zap.Error(err))
+	// Stop receiving before discarding the buffered messages, so that none is added to the buffer afterwards.
+	close(n.done)
+	if n.cancel != nil {
+		n.cancel()
+		n.wg.Wait()
+	}
+	var discarded int
+	// The JetStream subscriptions are not unsubscribed explicitly, because the client deletes the consumer it created
+	// on Unsubscribe, while the durable consumer has to survive restarts. Closing the connection releases them.
+	if n.js == nil {
+		discarded = n.pendingMessages()
+		n.unsubscribe()
+	}
+	discarded += n.discardBuffered()
+	if discarded > 0 {
+		n.logger.Warn("Discarded the messages which have not been read", zap.Int("count", discarded))
+	}
+	if n.kvWatcher != nil {
+		if err := n.kvWatcher.Stop(); err != nil {
+			n.logger.Error("Failed to stop KV watcher", zap.Error(err))
+		}
+	}
+	for _, advisory := range n.advisories {
+		if err := advisory.Unsubscribe(); err != nil {
+			n.logger.Error("Failed to unsubscribe JetStream advisories", zap.String("subject", advisory.Subject), zap.Error(err))
+		}
 	}
 	n.natsConn.Close()
+	if n.secretWatcher != nil {
+		if err := n.secretWatcher.Close(); err != nil {
+			n.logger.Error("Failed to close secret watcher", zap.Error(err))
+		}
+	}
 	n.logger.Info("NATS source server shutdown")
 	return nil
 }
+
+// Drain stops receiving new messages and waits for the buffered ones to be read, up to the drain timeout.
+// It has to be called while Numaflow can still read from the source, i.e. before the source server is stopped.
+// It is a no-op when draining is not configured.
+func (n *natsSource) Drain() {
+	if n.drainTimeout <= 0 {
+		return
+	}
+	n.logger.Info("Draining nats source...", zap.Duration("timeout", n.drainTimeout))
+	if n.cancel != nil {
+		n.cancel()
+		n.wg.Wait()
+	} else {
+		n.subsMu.RLock()
+		for _, sub := range n.subs {
+			if err := sub.Drain(); err != nil {
+				n.logger.Error("Failed to drain nats subscription", zap.String("subject", sub.Subject), zap.Error(err))
+			}
+		}
+		n.subsMu.RUnlock()
+	}
+
+	timer := time.NewTimer(n.drainTimeout)
+	defer timer.Stop()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for !n.drained() {
+		select {
+		case <-timer.C:
+			n.logger.Warn("Timed out draining nats source")
+			return
+		case <-ticker.C:
+		}
+	}
+	n.logger.Info("NATS source drained")
+}
+
+// drained returns whether all the received messages have been read.
+func (n *natsSource) drained() bool {
+	if len(n.messages) > 0 {
+		return false
+	}
+	n.subsMu.RLock()
+	defer n.subsMu.RUnlock()
+	for _, sub := range n.subs {
+		// A draining core NATS subscription becomes invalid once all its pending messages are processed.
+		if n.js == nil && sub.IsValid() {
+			return false
+		}
+	}
+	return true
+}
+
+// pendingMessages returns the number of messages received by the core NATS subscriptions but not buffered yet.
+func (n *natsSource) pendingMessages() int {
+	n.subsMu.RLock()
+	defer n.subsMu.RUnlock()
+	var pending int
+	for _, sub := range n.subs {
+		if msgs, _, err := sub.Pending(); err == nil {
+			pending += msgs
+		}
+	}
+	return pending
+}
+
+// discardBuffered empties the message buffer and returns the number of messages which have not been read.
+// The JetStream messages are negatively acknowledged so that they are redelivered without waiting for AckWait.
+func (n *natsSource) discardBuffered() int {
+	var discarded int
+	for {
+		select {
+		case m := <-n.messages:
+			discarded++
+			if m.msg != nil {
+				if err := m.msg.NakWithDelay(closeNakDelay); err != nil {
+					n.logger.Error("Failed to nak JetStream message", zap.Error(err))
+				}
+			}
+		default:
+			return discarded
+		}
+	}
+}
+
+// unsubscribe removes all the core NATS subscriptions.
+func (n *natsSource) unsubscribe() {
+	n.subsMu.Lock()
+	subs := n.subs
+	n.subs = nil
+	n.subsMu.Unlock()
+	for _, sub := range subs {
+		// A drained subscription is already unsubscribed.
+		if !sub.IsValid() {
+			continue
+		}
+		if err := sub.Unsubscribe(); err != nil {
+			n.logger.Error("Failed to unsubscribe nats subscription", zap.String("subject", sub.Subject), zap.Error(err))
+		}
+	}
+}
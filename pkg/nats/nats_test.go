@@ -3,18 +3,30 @@ package nats
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"strconv"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/nats-io/jwt/v2"
 	"github.com/nats-io/nats-server/v2/server"
 	natstestserver "github.com/nats-io/nats-server/v2/test"
 	natslib "github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
 	sourcesdk "github.com/numaproj/numaflow-go/pkg/sourcer"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/numaproj-contrib/nats-source-go/pkg/config"
+	"github.com/numaproj-contrib/nats-source-go/pkg/metrics"
+	"github.com/numaproj-contrib/nats-source-go/pkg/utils"
 )
 
 type TestReadRequest struct {
@@ -30,6 +42,23 @@ func (rr TestReadRequest) TimeOut() time.Duration {
 	return rr.timeout
 }
 
+type TestAckRequest struct {
+	offsets []sourcesdk.Offset
+}
+
+func (ar TestAckRequest) Offsets() []sourcesdk.Offset {
+	return ar.offsets
+}
+
+// ackAll acknowledges all the messages in the channel.
+func ackAll(ns *natsSource, messageCh chan sourcesdk.Message) {
+	var offsets []sourcesdk.Offset
+	for len(messageCh) > 0 {
+		offsets = append(offsets, (<-messageCh).Offset())
+	}
+	ns.Ack(context.Background(), TestAckRequest{offsets: offsets})
+}
+
 // Test_Single tests a single source reading from a single nats subject
 func Test_Single(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -68,81 +97,1919 @@ func Test_Single(t *testing.T) {
 	assert.Equal(t, 3, len(messageCh))
 }
 
-// Test_Multiple tests multiple sources reading from a single nats subject
-func Test_Multiple(t *testing.T) {
+// Test_CredentialsAuth tests the decentralized JWT authentication with a creds file and with separate JWT and seed secrets
+func Test_CredentialsAuth(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
-	server := RunNatsServer(t)
-	defer server.Shutdown()
+
+	operatorKP, err := nkeys.CreateOperator()
+	assert.NoError(t, err)
+	operatorPub, err := operatorKP.PublicKey()
+	assert.NoError(t, err)
+	operatorClaims := jwt.NewOperatorClaims(operatorPub)
+	accountKP, err := nkeys.CreateAccount()
+	assert.NoError(t, err)
+	accountPub, err := accountKP.PublicKey()
+	assert.NoError(t, err)
+	accountJWT, err := jwt.NewAccountClaims(accountPub).Encode(operatorKP)
+	assert.NoError(t, err)
+	userKP, err := nkeys.CreateUser()
+	assert.NoError(t, err)
+	userPub, err := userKP.PublicKey()
+	assert.NoError(t, err)
+	userJWT, err := jwt.NewUserClaims(userPub).Encode(accountKP)
+	assert.NoError(t, err)
+	userSeed, err := userKP.Seed()
+	assert.NoError(t, err)
+	creds, err := jwt.FormatUserConfig(userJWT, userSeed)
+	assert.NoError(t, err)
+
+	resolver := &server.MemAccResolver{}
+	assert.NoError(t, resolver.Store(accountPub, accountJWT))
+	opts := natstestserver.DefaultTestOptions
+	opts.TrustedOperators = []*jwt.OperatorClaims{operatorClaims}
+	opts.AccountResolver = resolver
+	s := natstestserver.RunServer(&opts)
+	defer s.Shutdown()
+
+	// Prepare the secrets
+	secretPath := t.TempDir()
+	assert.NoError(t, os.MkdirAll(fmt.Sprintf("%s/nats-creds", secretPath), 0750))
+	assert.NoError(t, os.WriteFile(fmt.Sprintf("%s/nats-creds/user.creds", secretPath), creds, 0600))
+	assert.NoError(t, os.WriteFile(fmt.Sprintf("%s/nats-creds/user.jwt", secretPath), []byte(userJWT), 0600))
+	assert.NoError(t, os.WriteFile(fmt.Sprintf("%s/nats-creds/user.nk", secretPath), userSeed, 0600))
+	selector := func(key string) *corev1.SecretKeySelector {
+		return &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "nats-creds"},
+			Key:                  key,
+		}
+	}
 
 	url := "127.0.0.1"
-	testSubject := "test-single"
-	testQueue := "test-queue-single"
+	nc, err := natslib.Connect(url, natslib.UserCredentials(fmt.Sprintf("%s/nats-creds/user.creds", secretPath)))
+	assert.NoError(t, err)
+	defer nc.Close()
 
-	config := &config.Config{
+	// Connecting without credentials is rejected.
+	_, err = New(&config.Config{URL: url, Subject: "test-creds"})
+	assert.Error(t, err)
+
+	tests := []struct {
+		name string
+		auth *config.Auth
+	}{
+		{name: "creds file", auth: &config.Auth{Credentials: selector("user.creds")}},
+		{name: "jwt and seed", auth: &config.Auth{JWT: &config.JWTAuth{JWT: selector("user.jwt"), Seed: selector("user.nk")}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testSubject := "test-creds"
+			ns, err := New(&config.Config{
+				URL:     url,
+				Subject: testSubject,
+				Queue:   "test-queue-creds",
+				Auth:    tt.auth,
+			}, WithVolumeReader(utils.NewNatsVolumeReader(secretPath)))
+			assert.NoError(t, err)
+			if err != nil {
+				return
+			}
+			defer ns.Close()
+
+			assert.NoError(t, nc.Publish(testSubject, []byte("test")))
+			messageCh := make(chan sourcesdk.Message, 10)
+			ns.Read(ctx, TestReadRequest{count: 1, timeout: 5 * time.Second}, messageCh)
+			assert.Equal(t, 1, len(messageCh))
+		})
+	}
+}
+
+// Test_TokenRotation tests that a rotated token is used when reconnecting
+func Test_TokenRotation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	opts := natstestserver.DefaultTestOptions
+	opts.Authorization = "token-1"
+	s := natstestserver.RunServer(&opts)
+
+	secretPath := t.TempDir()
+	tokenFile := fmt.Sprintf("%s/nats-token/token", secretPath)
+	assert.NoError(t, os.MkdirAll(fmt.Sprintf("%s/nats-token", secretPath), 0750))
+	assert.NoError(t, os.WriteFile(tokenFile, []byte("token-1\n"), 0600))
+
+	url := "127.0.0.1"
+	testSubject := "test-token-rotation"
+	ns, err := New(&config.Config{
 		URL:     url,
 		Subject: testSubject,
-		Queue:   testQueue,
+		Queue:   "test-queue-token-rotation",
+		Auth: &config.Auth{
+			Token: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "nats-token"},
+				Key:                  "token",
+			},
+		},
+	}, WithVolumeReader(utils.NewNatsVolumeReader(secretPath)))
+	assert.NoError(t, err)
+	defer ns.Close()
+
+	// Rotate the token on both sides.
+	assert.NoError(t, os.WriteFile(tokenFile, []byte("token-2\n"), 0600))
+	s.Shutdown()
+	opts.Authorization = "token-2"
+	s = natstestserver.RunServer(&opts)
+	defer s.Shutdown()
+
+	assert.Eventually(t, func() bool {
+		return ns.natsConn.IsConnected()
+	}, 20*time.Second, 100*time.Millisecond)
+	nc, err := natslib.Connect(url, natslib.Token("token-2"))
+	assert.NoError(t, err)
+	defer nc.Close()
+	assert.NoError(t, nc.Publish(testSubject, []byte("test")))
+	messageCh := make(chan sourcesdk.Message, 10)
+	ns.Read(ctx, TestReadRequest{count: 1, timeout: 5 * time.Second}, messageCh)
+	assert.Equal(t, 1, len(messageCh))
+}
+
+// Test_MultipleSubjects tests a single source reading from multiple nats subjects
+func Test_MultipleSubjects(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	server := RunNatsServer(t)
+	defer server.Shutdown()
+
+	url := "127.0.0.1"
+	ns, err := New(&config.Config{
+		URL:     url,
+		Subject: "orders.>",
+		Queue:   "test-queue-orders",
+		Subscriptions: []config.Subscription{
+			{Subject: "payments.>", Queue: "test-queue-payments"},
+			{Subject: "refunds"},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(ns.subs))
+	assert.Equal(t, "test-queue-orders", ns.subs[2].Queue)
+
+	nc, err := natslib.Connect(url)
+	assert.NoError(t, err)
+	defer nc.Close()
+	for i, subject := range []string{"orders.eu", "payments.us", "refunds"} {
+		err = nc.Publish(subject, []byte(fmt.Sprintf("%d", i)))
+		assert.NoError(t, err)
 	}
 
-	ns1, err := New(config)
-	defer ns1.Close()
+	messageCh := make(chan sourcesdk.Message, 10)
+	ns.Read(ctx, TestReadRequest{count: 3, timeout: 5 * time.Second}, messageCh)
+	assert.Equal(t, 3, len(messageCh))
+
+	subs := ns.subs
+	assert.NoError(t, ns.Close())
+	for _, sub := range subs {
+		assert.False(t, sub.IsValid())
+	}
+}
+
+// Test_Keys tests that the message keys are extracted from the subject, headers and payload
+func Test_Keys(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	server := RunNatsServer(t)
+	defer server.Shutdown()
+
+	url := "127.0.0.1"
+
+	ns, err := New(&config.Config{
+		URL:     url,
+		Subject: "orders.*.created",
+		Queue:   "test-queue-keys",
+		Keys: &config.Keys{
+			SubjectTokens: []int{2},
+			Header:        "Tenant",
+			JSONPath:      "customer.id",
+		},
+	})
 	assert.NoError(t, err)
-	assert.NotNil(t, ns1)
+	defer ns.Close()
 
-	ns2, err := New(config)
-	defer ns2.Close()
+	nc, err := natslib.Connect(url)
 	assert.NoError(t, err)
-	assert.NotNil(t, ns2)
+	defer nc.Close()
+	msg := natslib.NewMsg("orders.eu.created")
+	msg.Header.Set("Tenant", "tenant-1")
+	msg.Data = []byte(`{"customer":{"id":"c-1"}}`)
+	assert.NoError(t, nc.PublishMsg(msg))
+	// The keys which cannot be extracted are skipped.
+	assert.NoError(t, nc.Publish("orders.us.created", []byte("not json")))
+
+	messageCh := make(chan sourcesdk.Message, 10)
+	ns.Read(ctx, TestReadRequest{count: 2, timeout: 5 * time.Second}, messageCh)
+	assert.Equal(t, 2, len(messageCh))
+	assert.Equal(t, []string{"eu", "tenant-1", "c-1"}, (<-messageCh).Keys())
+	assert.Equal(t, []string{"us"}, (<-messageCh).Keys())
+}
+
+// Test_EventTime tests that the event time is extracted from the headers and the payload
+func Test_EventTime(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	server := RunNatsServer(t)
+	defer server.Shutdown()
 
+	url := "127.0.0.1"
 	nc, err := natslib.Connect(url)
+	assert.NoError(t, err)
 	defer nc.Close()
+	eventTime := time.Date(2023, 9, 5, 19, 18, 44, 0, time.UTC)
+
+	t.Run("header with drop fallback", func(t *testing.T) {
+		testSubject := "test-event-time-header"
+		ns, err := New(&config.Config{
+			URL:     url,
+			Subject: testSubject,
+			Queue:   "test-queue-event-time",
+			EventTime: &config.EventTime{
+				Source:   config.EventTimeFromHeader,
+				Header:   "Event-Time",
+				Format:   config.EventTimeFormatEpochMillis,
+				Fallback: config.EventTimeFallbackDrop,
+			},
+		})
+		assert.NoError(t, err)
+		defer ns.Close()
+
+		msg := natslib.NewMsg(testSubject)
+		msg.Header.Set("Event-Time", strconv.FormatInt(eventTime.UnixMilli(), 10))
+		assert.NoError(t, nc.PublishMsg(msg))
+		// The message without the header is dropped.
+		assert.NoError(t, nc.Publish(testSubject, []byte("no header")))
+
+		messageCh := make(chan sourcesdk.Message, 10)
+		ns.Read(ctx, TestReadRequest{count: 2, timeout: time.Second}, messageCh)
+		assert.Equal(t, 1, len(messageCh))
+		assert.True(t, eventTime.Equal((<-messageCh).EventTime()))
+	})
+
+	t.Run("payload with now fallback", func(t *testing.T) {
+		testSubject := "test-event-time-payload"
+		ns, err := New(&config.Config{
+			URL:     url,
+			Subject: testSubject,
+			Queue:   "test-queue-event-time",
+			EventTime: &config.EventTime{
+				Source:   config.EventTimeFromPayload,
+				JSONPath: "meta.createdAt",
+			},
+		})
+		assert.NoError(t, err)
+		defer ns.Close()
+
+		assert.NoError(t, nc.Publish(testSubject, []byte(`{"meta":{"createdAt":"2023-09-05T19:18:44Z"}}`)))
+		assert.NoError(t, nc.Publish(testSubject, []byte(`{"meta":{"createdAt":"yesterday"}}`)))
+
+		messageCh := make(chan sourcesdk.Message, 10)
+		before := time.Now()
+		ns.Read(ctx, TestReadRequest{count: 2, timeout: 5 * time.Second}, messageCh)
+		assert.Equal(t, 2, len(messageCh))
+		assert.True(t, eventTime.Equal((<-messageCh).EventTime()))
+		assert.False(t, (<-messageCh).EventTime().Before(before))
+	})
+}
+
+// Test_Pending tests the pending count reported for a core NATS subscription
+func Test_Pending(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	server := RunNatsServer(t)
+	defer server.Shutdown()
+
+	url := "127.0.0.1"
+	testSubject := "test-pending"
+
+	ns, err := New(&config.Config{
+		URL:     url,
+		Subject: testSubject,
+		Queue:   "test-queue-pending",
+	})
 	assert.NoError(t, err)
+	defer ns.Close()
+	assert.Equal(t, int64(0), ns.Pending(ctx))
 
+	nc, err := natslib.Connect(url)
+	assert.NoError(t, err)
+	defer nc.Close()
 	for i := 0; i < 5; i++ {
 		err = nc.Publish(testSubject, []byte(fmt.Sprintf("%d", i)))
 		assert.NoError(t, err)
 	}
-	messageCh1 := make(chan sourcesdk.Message, 10)
-	messageCh2 := make(chan sourcesdk.Message, 10)
+	assert.Eventually(t, func() bool {
+		return ns.Pending(ctx) == 5
+	}, 5*time.Second, 10*time.Millisecond)
 
-	var wg sync.WaitGroup
-	wg.Add(2)
+	messageCh := make(chan sourcesdk.Message, 10)
+	ns.Read(ctx, TestReadRequest{count: 2, timeout: time.Second}, messageCh)
+	assert.Equal(t, 2, len(messageCh))
+	assert.Equal(t, int64(3), ns.Pending(ctx))
+}
+
+// Test_Drain tests that the buffered messages can still be read while the source is draining,
+// before it is closed
+func Test_Drain(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	server := RunNatsServer(t)
+	defer server.Shutdown()
+
+	url := "127.0.0.1"
+	testSubject := "test-drain"
+
+	core, logs := observer.New(zap.InfoLevel)
+	ns, err := New(&config.Config{
+		URL:     url,
+		Subject: testSubject,
+		Queue:   "test-queue-drain",
+		Drain: &config.Drain{
+			Timeout: &config.Duration{Duration: 10 * time.Second},
+		},
+	}, WithLogger(zap.New(core)))
+	assert.NoError(t, err)
+
+	nc, err := natslib.Connect(url)
+	assert.NoError(t, err)
+	defer nc.Close()
+	for i := 0; i < 3; i++ {
+		err = nc.Publish(testSubject, []byte(fmt.Sprintf("%d", i)))
+		assert.NoError(t, err)
+	}
+	assert.Eventually(t, func() bool {
+		return len(ns.messages) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	drained := make(chan struct{})
+	start := time.Now()
 	go func() {
-		defer wg.Done()
-		for {
-			ns1.Read(ctx, TestReadRequest{count: 1, timeout: time.Second}, messageCh1)
-			if len(messageCh1)+len(messageCh2) == 5 {
-				break
+		defer close(drained)
+		ns.Drain()
+	}()
+	messageCh := make(chan sourcesdk.Message, 10)
+	ns.Read(ctx, TestReadRequest{count: 3, timeout: 5 * time.Second}, messageCh)
+	assert.Equal(t, 3, len(messageCh))
+	<-drained
+	assert.Less(t, time.Since(start), 10*time.Second)
+	assert.Len(t, logs.FilterMessage("NATS source drained").All(), 1)
+
+	assert.NoError(t, ns.Close())
+	assert.Empty(t, logs.FilterMessage("Discarded the messages which have not been read").All())
+}
+
+// Test_Close tests that the buffered messages are discarded and logged right away on close
+func Test_Close(t *testing.T) {
+	server := RunNatsServer(t)
+	defer server.Shutdown()
+
+	url := "127.0.0.1"
+	testSubject := "test-close"
+
+	core, logs := observer.New(zap.InfoLevel)
+	ns, err := New(&config.Config{
+		URL:     url,
+		Subject: testSubject,
+		Queue:   "test-queue-close",
+	}, WithLogger(zap.New(core)))
+	assert.NoError(t, err)
+
+	nc, err := natslib.Connect(url)
+	assert.NoError(t, err)
+	defer nc.Close()
+	for i := 0; i < 3; i++ {
+		err = nc.Publish(testSubject, []byte(fmt.Sprintf("%d", i)))
+		assert.NoError(t, err)
+	}
+	assert.Eventually(t, func() bool {
+		return len(ns.messages) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	start := time.Now()
+	assert.NoError(t, ns.Close())
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, 0, len(ns.messages))
+	discarded := logs.FilterMessage("Discarded the messages which have not been read").All()
+	if assert.Len(t, discarded, 1) {
+		assert.Equal(t, int64(3), discarded[0].ContextMap()["count"])
+	}
+}
+
+// Test_BufferOverflow tests the overflow policies of the buffer
+func Test_BufferOverflow(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	server := RunNatsServer(t)
+	defer server.Shutdown()
+
+	url := "127.0.0.1"
+	nc, err := natslib.Connect(url)
+	assert.NoError(t, err)
+	defer nc.Close()
+
+	tests := []struct {
+		policy config.OverflowPolicy
+		want   []string
+	}{
+		{policy: config.OverflowDropNewest, want: []string{"0", "1"}},
+		{policy: config.OverflowDropOldest, want: []string{"3", "4"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			testSubject := "test-overflow-" + string(tt.policy)
+			ns, err := New(&config.Config{
+				URL:     url,
+				Subject: testSubject,
+				Queue:   "test-queue-overflow",
+				Buffer: &config.Buffer{
+					Size:           2,
+					OverflowPolicy: tt.policy,
+				},
+			})
+			assert.NoError(t, err)
+			defer ns.Close()
+
+			for i := 0; i < 5; i++ {
+				err = nc.Publish(testSubject, []byte(fmt.Sprintf("%d", i)))
+				assert.NoError(t, err)
 			}
-		}
-		close(messageCh1)
+			assert.Eventually(t, func() bool {
+				return ns.dropped.Load() == 3
+			}, 5*time.Second, 10*time.Millisecond)
+
+			messageCh := make(chan sourcesdk.Message, 10)
+			ns.Read(ctx, TestReadRequest{count: 5, timeout: time.Second}, messageCh)
+			close(messageCh)
+			var got []string
+			for m := range messageCh {
+				got = append(got, string(m.Value()))
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// Test_Metrics tests the metrics recorded by a source
+func Test_Metrics(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	server := RunNatsServer(t)
+	defer server.Shutdown()
+
+	url := "127.0.0.1"
+	testSubject := "test-metrics"
+	m := metrics.New()
+	ns, err := New(&config.Config{
+		URL:     url,
+		Subject: testSubject,
+		Queue:   "test-queue-metrics",
+		Buffer: &config.Buffer{
+			Size:           2,
+			OverflowPolicy: config.OverflowDropNewest,
+		},
+	}, WithMetrics(m))
+	assert.NoError(t, err)
+	defer ns.Close()
+
+	nc, err := natslib.Connect(url)
+	assert.NoError(t, err)
+	defer nc.Close()
+	for i := 0; i < 3; i++ {
+		err = nc.Publish(testSubject, []byte("hello"))
+		assert.NoError(t, err)
+	}
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(m.MessagesReceived.WithLabelValues(testSubject)) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, float64(15), testutil.ToFloat64(m.BytesReceived.WithLabelValues(testSubject)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.MessagesDropped.WithLabelValues(testSubject, metrics.ReasonBufferFull)))
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, metrics.Path, nil))
+	assert.Contains(t, rec.Body.String(), "nats_source_buffered_messages 2")
+
+	messageCh := make(chan sourcesdk.Message, 10)
+	ns.Read(ctx, TestReadRequest{count: 2, timeout: time.Second}, messageCh)
+	assert.Equal(t, 2, len(messageCh))
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.MessagesRead.WithLabelValues(testSubject)))
+	assert.Equal(t, 1, testutil.CollectAndCount(m.ReadLatency))
+
+	server.Shutdown()
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(m.Disconnects) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// Test_Health tests the liveness and readiness of a source losing its connection to NATS
+func Test_Health(t *testing.T) {
+	server := RunNatsServer(t)
+	defer func() {
+		server.Shutdown()
 	}()
+
+	ns, err := New(&config.Config{
+		URL:     "127.0.0.1",
+		Subject: "test-health",
+		Queue:   "test-queue-health",
+		Health: &config.Health{
+			DisconnectGracePeriod: &config.Duration{Duration: 500 * time.Millisecond},
+		},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, ns.Live())
+	assert.NoError(t, ns.Ready())
+
+	server.Shutdown()
+	assert.Eventually(t, func() bool {
+		return ns.Ready() != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	// Still live within the grace period.
+	assert.NoError(t, ns.Live())
+	assert.Eventually(t, func() bool {
+		return ns.Live() != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Contains(t, ns.Live().Error(), "nats disconnected for")
+
+	server = RunNatsServer(t)
+	assert.Eventually(t, func() bool {
+		return ns.Ready() == nil && ns.Live() == nil
+	}, 10*time.Second, 10*time.Millisecond)
+
+	// The probes keep being served while the source is closed.
+	probing := make(chan struct{})
+	stop := make(chan struct{})
 	go func() {
-		defer wg.Done()
+		defer close(probing)
 		for {
-			ns2.Read(ctx, TestReadRequest{count: 1, timeout: time.Second}, messageCh2)
-			if len(messageCh1)+len(messageCh2) == 5 {
-				break
+			select {
+			case <-stop:
+				return
+			default:
+				_ = ns.Ready()
 			}
 		}
-		close(messageCh2)
 	}()
-	wg.Wait()
+	assert.NoError(t, ns.Close())
+	close(stop)
+	<-probing
+	assert.EqualError(t, ns.Live(), "nats connection is closed")
+	assert.Error(t, ns.Ready())
+}
 
-	var sum int
-	for m := range messageCh1 {
-		byteToInt, err := strconv.Atoi(string(m.Value()))
-		assert.NoError(t, err)
-		sum += byteToInt
+// Test_ConnectRetry tests a source started before the nats server
+func Test_ConnectRetry(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	url := "127.0.0.1"
+	testSubject := "test-connect-retry"
+	jitter := 0.0
+	type result struct {
+		ns  *natsSource
+		err error
 	}
-	for m := range messageCh2 {
-		byteToInt, err := strconv.Atoi(string(m.Value()))
-		assert.NoError(t, err)
-		sum += byteToInt
+	started := make(chan result, 1)
+	go func() {
+		ns, err := New(&config.Config{
+			URL:     url,
+			Subject: testSubject,
+			Queue:   "test-queue-connect-retry",
+			ConnectRetry: &config.ConnectRetry{
+				MaxAttempts:    -1,
+				InitialBackoff: &config.Duration{Duration: 50 * time.Millisecond},
+				MaxBackoff:     &config.Duration{Duration: 200 * time.Millisecond},
+				Jitter:         &jitter,
+			},
+		})
+		started <- result{ns: ns, err: err}
+	}()
+
+	time.Sleep(500 * time.Millisecond)
+	select {
+	case <-started:
+		t.Fatal("source started without a nats server")
+	default:
 	}
-	assert.Equal(t, 10, sum)
-}
+	server := RunNatsServer(t)
+	defer server.Shutdown()
+
+	var res result
+	select {
+	case res = <-started:
+	case <-ctx.Done():
+		t.Fatal("source did not start")
+	}
+	assert.NoError(t, res.err)
+	defer res.ns.Close()
+
+	nc, err := natslib.Connect(url)
+	assert.NoError(t, err)
+	defer nc.Close()
+	err = nc.Publish(testSubject, []byte("hello"))
+	assert.NoError(t, err)
+
+	messageCh := make(chan sourcesdk.Message, 1)
+	res.ns.Read(ctx, TestReadRequest{count: 1, timeout: 5 * time.Second}, messageCh)
+	assert.Equal(t, 1, len(messageCh))
+}
+
+// Test_ConnectRetryExhausted tests a source giving up connecting to a nats server which is not running
+func Test_ConnectRetryExhausted(t *testing.T) {
+	start := time.Now()
+	_, err := New(&config.Config{
+		URL:     "127.0.0.1",
+		Subject: "test-connect-retry",
+		ConnectRetry: &config.ConnectRetry{
+			MaxAttempts:    3,
+			InitialBackoff: &config.Duration{Duration: 100 * time.Millisecond},
+		},
+	})
+	assert.ErrorIs(t, err, natslib.ErrNoServers)
+	assert.Contains(t, err.Error(), "after 3 attempts")
+	// Two backoffs of 100ms and 200ms, plus up to 20% jitter.
+	assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+
+	_, err = New(&config.Config{
+		URL:     "127.0.0.1",
+		Subject: "test-connect-retry",
+	})
+	assert.ErrorIs(t, err, natslib.ErrNoServers)
+	assert.NotContains(t, err.Error(), "attempts")
+}
+
+// Test_ConnectRetryBackoff tests the exponential backoff between the connection attempts, with and without jitter
+func Test_ConnectRetryBackoff(t *testing.T) {
+	jitter := 0.0
+	retry := newConnectRetry(&config.ConnectRetry{
+		InitialBackoff: &config.Duration{Duration: time.Second},
+		MaxBackoff:     &config.Duration{Duration: 5 * time.Second},
+		Jitter:         &jitter,
+	})
+	assert.Equal(t, 10, retry.maxAttempts)
+	var backoffs []time.Duration
+	for attempt := 1; attempt <= 5; attempt++ {
+		backoffs = append(backoffs, retry.backoff(attempt))
+	}
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}, backoffs)
+
+	retry.jitter = 0.5
+	for attempt := 1; attempt <= 5; attempt++ {
+		backoff := retry.backoff(attempt)
+		assert.GreaterOrEqual(t, backoff, backoffs[attempt-1])
+		assert.LessOrEqual(t, backoff, backoffs[attempt-1]*3/2)
+	}
+	assert.Equal(t, 1, newConnectRetry(nil).maxAttempts)
+}
+
+// Test_Connection tests the tuning of the nats connection
+func Test_Connection(t *testing.T) {
+	server := RunNatsServer(t)
+	defer server.Shutdown()
+
+	maxReconnects := 5
+	ns, err := New(&config.Config{
+		URL:     "127.0.0.1",
+		Subject: "test-connection",
+		Queue:   "test-queue-connection",
+		Connection: &config.Connection{
+			Name:                 "test-source",
+			ReconnectWait:        &config.Duration{Duration: time.Second},
+			MaxReconnects:        &maxReconnects,
+			PingInterval:         &config.Duration{Duration: 10 * time.Second},
+			MaxPingsOutstanding:  3,
+			InboxPrefix:          "_TEST_INBOX",
+			ReconnectBufferSize:  -1,
+			PendingMessagesLimit: 10,
+		},
+	})
+	assert.NoError(t, err)
+	defer ns.Close()
+
+	opts := ns.natsConn.Opts
+	assert.Equal(t, "test-source", opts.Name)
+	assert.Equal(t, time.Second, opts.ReconnectWait)
+	assert.Equal(t, 5, opts.MaxReconnect)
+	assert.Equal(t, 10*time.Second, opts.PingInterval)
+	assert.Equal(t, 3, opts.MaxPingsOut)
+	assert.Equal(t, "_TEST_INBOX", opts.InboxPrefix)
+	assert.Equal(t, -1, opts.ReconnectBufSize)
+
+	msgLimit, bytesLimit, err := ns.subs[0].PendingLimits()
+	assert.NoError(t, err)
+	assert.Equal(t, 10, msgLimit)
+	assert.Equal(t, natslib.DefaultSubPendingBytesLimit, bytesLimit)
+
+	connz, err := server.Connz(nil)
+	assert.NoError(t, err)
+	var names []string
+	for _, conn := range connz.Conns {
+		names = append(names, conn.Name)
+	}
+	assert.Contains(t, names, "test-source")
+}
+
+// Test_ReadCancel tests a read returning as soon as its context is cancelled
+func Test_ReadCancel(t *testing.T) {
+	server := RunNatsServer(t)
+	defer server.Shutdown()
+
+	ns, err := New(&config.Config{
+		URL:     "127.0.0.1",
+		Subject: "test-read-cancel",
+		Queue:   "test-queue-read-cancel",
+	})
+	assert.NoError(t, err)
+	defer ns.Close()
+
+	readRequest := TestReadRequest{count: 10, timeout: 10 * time.Second}
+	messageCh := make(chan sourcesdk.Message, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+	start := time.Now()
+	ns.Read(ctx, readRequest, messageCh)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// A cancelled read doesn't take any message from the buffer.
+	nc, err := natslib.Connect("127.0.0.1")
+	assert.NoError(t, err)
+	defer nc.Close()
+	err = nc.Publish("test-read-cancel", []byte("hello"))
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return len(ns.messages) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	ns.Read(ctx, readRequest, messageCh)
+	assert.Equal(t, 0, len(messageCh))
+	assert.Equal(t, 1, len(ns.messages))
+}
+
+// Test_ReadLinger tests a read returning shortly after the first message instead of waiting for its timeout
+func Test_ReadLinger(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	server := RunNatsServer(t)
+	defer server.Shutdown()
+
+	url := "127.0.0.1"
+	testSubject := "test-read-linger"
+	ns, err := New(&config.Config{
+		URL:     url,
+		Subject: testSubject,
+		Queue:   "test-queue-read-linger",
+	})
+	assert.NoError(t, err)
+	defer ns.Close()
+
+	nc, err := natslib.Connect(url)
+	assert.NoError(t, err)
+	defer nc.Close()
+
+	readRequest := TestReadRequest{count: 10, timeout: 10 * time.Second}
+	messageCh := make(chan sourcesdk.Message, 10)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_ = nc.Publish(testSubject, []byte("1"))
+		_ = nc.Publish(testSubject, []byte("2"))
+	}()
+	start := time.Now()
+	ns.Read(ctx, readRequest, messageCh)
+	elapsed := time.Since(start)
+	assert.GreaterOrEqual(t, elapsed, 100*time.Millisecond)
+	assert.Less(t, elapsed, time.Second)
+	// The second message is read within the linger window of the first one.
+	assert.Equal(t, 2, len(messageCh))
+}
+
+// Test_Partitions tests a source reading the partitions produced by a NATS subject mapping
+func Test_Partitions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	server := RunNatsServer(t)
+	defer server.Shutdown()
+	err := server.GlobalAccount().AddMapping("test-partitions.*", "test-partitions.{{partition(3,1)}}.{{wildcard(1)}}")
+	assert.NoError(t, err)
+
+	url := "127.0.0.1"
+	ns, err := New(&config.Config{
+		URL:   url,
+		Queue: "test-queue-partitions",
+		Partitions: &config.Partitions{
+			Count:           3,
+			SubjectTemplate: "test-partitions.{partition}.*",
+		},
+	})
+	assert.NoError(t, err)
+	defer ns.Close()
+	assert.Equal(t, []int32{0, 1, 2}, ns.Partitions(ctx))
+
+	nc, err := natslib.Connect(url)
+	assert.NoError(t, err)
+	defer nc.Close()
+	for i := 0; i < 30; i++ {
+		err = nc.Publish(fmt.Sprintf("test-partitions.%d", i), []byte(strconv.Itoa(i)))
+		assert.NoError(t, err)
+	}
+
+	messageCh := make(chan sourcesdk.Message, 30)
+	for len(messageCh) < 30 && ctx.Err() == nil {
+		ns.Read(ctx, TestReadRequest{count: 30, timeout: time.Second}, messageCh)
+	}
+	assert.Equal(t, 30, len(messageCh))
+	// The same key is always mapped to the same partition, and the keys are spread over all the partitions.
+	partitions := map[string]int32{}
+	counts := map[int32]int{}
+	for len(messageCh) > 0 {
+		m := <-messageCh
+		partitions[string(m.Value())] = m.Offset().PartitionId()
+		counts[m.Offset().PartitionId()]++
+	}
+	assert.Len(t, partitions, 30)
+	assert.Len(t, counts, 3)
+
+	for i := 0; i < 30; i++ {
+		err = nc.Publish(fmt.Sprintf("test-partitions.%d", i), []byte(strconv.Itoa(i)))
+		assert.NoError(t, err)
+	}
+	for len(messageCh) < 30 && ctx.Err() == nil {
+		ns.Read(ctx, TestReadRequest{count: 30, timeout: time.Second}, messageCh)
+	}
+	for len(messageCh) > 0 {
+		m := <-messageCh
+		assert.Equal(t, partitions[string(m.Value())], m.Offset().PartitionId())
+	}
+}
+
+// Test_Multiple tests multiple sources reading from a single nats subject
+func Test_Multiple(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	server := RunNatsServer(t)
+	defer server.Shutdown()
+
+	url := "127.0.0.1"
+	testSubject := "test-single"
+	testQueue := "test-queue-single"
+
+	config := &config.Config{
+		URL:     url,
+		Subject: testSubject,
+		Queue:   testQueue,
+	}
+
+	ns1, err := New(config)
+	defer ns1.Close()
+	assert.NoError(t, err)
+	assert.NotNil(t, ns1)
+
+	ns2, err := New(config)
+	defer ns2.Close()
+	assert.NoError(t, err)
+	assert.NotNil(t, ns2)
+
+	nc, err := natslib.Connect(url)
+	defer nc.Close()
+	assert.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		err = nc.Publish(testSubject, []byte(fmt.Sprintf("%d", i)))
+		assert.NoError(t, err)
+	}
+	messageCh1 := make(chan sourcesdk.Message, 10)
+	messageCh2 := make(chan sourcesdk.Message, 10)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for {
+			ns1.Read(ctx, TestReadRequest{count: 1, timeout: time.Second}, messageCh1)
+			if len(messageCh1)+len(messageCh2) == 5 {
+				break
+			}
+		}
+		close(messageCh1)
+	}()
+	go func() {
+		defer wg.Done()
+		for {
+			ns2.Read(ctx, TestReadRequest{count: 1, timeout: time.Second}, messageCh2)
+			if len(messageCh1)+len(messageCh2) == 5 {
+				break
+			}
+		}
+		close(messageCh2)
+	}()
+	wg.Wait()
+
+	var sum int
+	for m := range messageCh1 {
+		byteToInt, err := strconv.Atoi(string(m.Value()))
+		assert.NoError(t, err)
+		sum += byteToInt
+	}
+	for m := range messageCh2 {
+		byteToInt, err := strconv.Atoi(string(m.Value()))
+		assert.NoError(t, err)
+		sum += byteToInt
+	}
+	assert.Equal(t, 10, sum)
+}
+
+// Test_JetStream tests a source reading from a JetStream durable pull consumer,
+// including messages published while the source was down.
+func Test_JetStream(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	server := RunJetStreamServer(t)
+	defer server.Shutdown()
+
+	url := "127.0.0.1"
+	testSubject := "test-jetstream"
+	testStream := "test-stream"
+
+	nc, err := natslib.Connect(url)
+	assert.NoError(t, err)
+	defer nc.Close()
+	js, err := nc.JetStream()
+	assert.NoError(t, err)
+	_, err = js.AddStream(&natslib.StreamConfig{Name: testStream, Subjects: []string{testSubject}})
+	assert.NoError(t, err)
+
+	config := &config.Config{
+		URL:     url,
+		Subject: testSubject,
+		JetStream: &config.JetStream{
+			Stream:   testStream,
+			Consumer: "test-consumer",
+		},
+	}
+
+	// Messages published before the source starts are delivered.
+	for i := 0; i < 3; i++ {
+		_, err = js.Publish(testSubject, []byte(fmt.Sprintf("%d", i)))
+		assert.NoError(t, err)
+	}
+	ns, err := New(config)
+	assert.NoError(t, err)
+	messageCh := make(chan sourcesdk.Message, 10)
+	ns.Read(ctx, TestReadRequest{count: 3, timeout: 5 * time.Second}, messageCh)
+	assert.Equal(t, 3, len(messageCh))
+	ackAll(ns, messageCh)
+	assert.NoError(t, ns.Close())
+
+	// Messages published while the source is down are delivered once it comes back.
+	for i := 3; i < 5; i++ {
+		_, err = js.Publish(testSubject, []byte(fmt.Sprintf("%d", i)))
+		assert.NoError(t, err)
+	}
+	ns, err = New(config)
+	assert.NoError(t, err)
+	defer ns.Close()
+	messageCh = make(chan sourcesdk.Message, 10)
+	ns.Read(ctx, TestReadRequest{count: 5, timeout: 2 * time.Second}, messageCh)
+	assert.Equal(t, 2, len(messageCh))
+	close(messageCh)
+	var sum int
+	for m := range messageCh {
+		byteToInt, err := strconv.Atoi(string(m.Value()))
+		assert.NoError(t, err)
+		sum += byteToInt
+	}
+	assert.Equal(t, 7, sum)
+}
+
+// Test_JetStreamExistingConsumer tests that an existing consumer is bound whatever its deliver policy
+// when none is configured, and that a configured deliver policy must match it
+func Test_JetStreamExistingConsumer(t *testing.T) {
+	server := RunJetStreamServer(t)
+	defer server.Shutdown()
+
+	url := "127.0.0.1"
+	testSubject := "test-jetstream-existing"
+	testStream := "test-stream"
+
+	nc, err := natslib.Connect(url)
+	assert.NoError(t, err)
+	defer nc.Close()
+	js, err := nc.JetStream()
+	assert.NoError(t, err)
+	_, err = js.AddStream(&natslib.StreamConfig{Name: testStream, Subjects: []string{testSubject}})
+	assert.NoError(t, err)
+	_, err = js.AddConsumer(testStream, &natslib.ConsumerConfig{
+		Durable:       "test-consumer",
+		FilterSubject: testSubject,
+		DeliverPolicy: natslib.DeliverNewPolicy,
+		AckPolicy:     natslib.AckExplicitPolicy,
+	})
+	assert.NoError(t, err)
+
+	config := &config.Config{
+		URL:     url,
+		Subject: testSubject,
+		JetStream: &config.JetStream{
+			Stream:   testStream,
+			Consumer: "test-consumer",
+		},
+	}
+	ns, err := New(config)
+	if assert.NoError(t, err) {
+		assert.NoError(t, ns.Close())
+	}
+
+	config.JetStream.DeliverPolicy = "all"
+	_, err = New(config)
+	assert.ErrorContains(t, err, "deliver policy")
+}
+
+// Test_JetStreamPartitions tests a source reading from a JetStream consumer per partition
+func Test_JetStreamPartitions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	server := RunJetStreamServer(t)
+	defer server.Shutdown()
+
+	url := "127.0.0.1"
+	testStream := "test-stream-partitions"
+	nc, err := natslib.Connect(url)
+	assert.NoError(t, err)
+	defer nc.Close()
+	js, err := nc.JetStream()
+	assert.NoError(t, err)
+	_, err = js.AddStream(&natslib.StreamConfig{Name: testStream, Subjects: []string{"test-jetstream-partitions.>"}})
+	assert.NoError(t, err)
+
+	ns, err := New(&config.Config{
+		URL: url,
+		JetStream: &config.JetStream{
+			Stream:   testStream,
+			Consumer: "test-consumer",
+		},
+		Partitions: &config.Partitions{
+			Count:           2,
+			SubjectTemplate: "test-jetstream-partitions.{partition}.>",
+		},
+	})
+	assert.NoError(t, err)
+	defer ns.Close()
+	assert.Equal(t, []int32{0, 1}, ns.Partitions(ctx))
+
+	for _, subject := range []string{"test-jetstream-partitions.0.a", "test-jetstream-partitions.1.b", "test-jetstream-partitions.1.c"} {
+		_, err = js.Publish(subject, []byte(subject))
+		assert.NoError(t, err)
+	}
+
+	messageCh := make(chan sourcesdk.Message, 10)
+	for len(messageCh) < 3 && ctx.Err() == nil {
+		ns.Read(ctx, TestReadRequest{count: 3, timeout: time.Second}, messageCh)
+	}
+	assert.Equal(t, 3, len(messageCh))
+	var offsets []sourcesdk.Offset
+	got := map[string]int32{}
+	for len(messageCh) > 0 {
+		m := <-messageCh
+		got[string(m.Value())] = m.Offset().PartitionId()
+		offsets = append(offsets, m.Offset())
+	}
+	assert.Equal(t, map[string]int32{
+		"test-jetstream-partitions.0.a": 0,
+		"test-jetstream-partitions.1.b": 1,
+		"test-jetstream-partitions.1.c": 1,
+	}, got)
+	ns.Ack(ctx, TestAckRequest{offsets: offsets})
+
+	// Each partition has its own durable consumer.
+	for partition, delivered := range []uint64{1, 2} {
+		assert.Eventually(t, func() bool {
+			info, err := js.ConsumerInfo(testStream, fmt.Sprintf("test-consumer-%d", partition))
+			return err == nil && info.AckFloor.Consumer == delivered && info.NumAckPending == 0
+		}, 5*time.Second, 10*time.Millisecond)
+	}
+}
+
+// Test_JetStreamReplay tests the deliver policies used to replay a stream from a given point
+func Test_JetStreamReplay(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	server := RunJetStreamServer(t)
+	defer server.Shutdown()
+
+	url := "127.0.0.1"
+	testStream := "test-stream-replay"
+	nc, err := natslib.Connect(url)
+	assert.NoError(t, err)
+	defer nc.Close()
+	js, err := nc.JetStream()
+	assert.NoError(t, err)
+	_, err = js.AddStream(&natslib.StreamConfig{Name: testStream, Subjects: []string{"test-replay.>"}})
+	assert.NoError(t, err)
+
+	publish := func(subject, data string) {
+		_, err := js.Publish(subject, []byte(data))
+		assert.NoError(t, err)
+	}
+	publish("test-replay.a", "1")
+	publish("test-replay.b", "2")
+	publish("test-replay.a", "3")
+	// Leave a gap so that the start time falls between two messages, whatever the clock resolution.
+	time.Sleep(50 * time.Millisecond)
+	startTime := time.Now()
+	time.Sleep(50 * time.Millisecond)
+	publish("test-replay.b", "4")
+	publish("test-replay.a", "5")
+
+	tests := []struct {
+		name      string
+		jetStream *config.JetStream
+		want      []string
+	}{
+		{
+			name:      "all",
+			jetStream: &config.JetStream{DeliverPolicy: config.DeliverAll},
+			want:      []string{"1", "2", "3", "4", "5"},
+		},
+		{
+			name:      "last",
+			jetStream: &config.JetStream{DeliverPolicy: config.DeliverLast},
+			want:      []string{"5"},
+		},
+		{
+			name:      "by start sequence",
+			jetStream: &config.JetStream{DeliverPolicy: config.DeliverByStartSequence, StartSequence: 3},
+			want:      []string{"3", "4", "5"},
+		},
+		{
+			name:      "by start time",
+			jetStream: &config.JetStream{DeliverPolicy: config.DeliverByStartTime, StartTime: &metav1.Time{Time: startTime}},
+			want:      []string{"4", "5"},
+		},
+		{
+			name:      "last per subject",
+			jetStream: &config.JetStream{DeliverPolicy: config.DeliverLastPerSubject},
+			want:      []string{"4", "5"},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.jetStream.Stream = testStream
+			tt.jetStream.Consumer = fmt.Sprintf("test-replay-%d", i)
+			ns, err := New(&config.Config{
+				URL:       url,
+				Subject:   "test-replay.>",
+				JetStream: tt.jetStream,
+			})
+			assert.NoError(t, err)
+			defer ns.Close()
+
+			messageCh := make(chan sourcesdk.Message, 10)
+			for len(messageCh) < len(tt.want) && ctx.Err() == nil {
+				ns.Read(ctx, TestReadRequest{count: 10, timeout: time.Second}, messageCh)
+			}
+			// Nothing else is delivered.
+			ns.Read(ctx, TestReadRequest{count: 10, timeout: 200 * time.Millisecond}, messageCh)
+			close(messageCh)
+			var got []string
+			for m := range messageCh {
+				got = append(got, string(m.Value()))
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// Test_JetStreamEventTime tests that the event time is the JetStream publish time
+func Test_JetStreamEventTime(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	server := RunJetStreamServer(t)
+	defer server.Shutdown()
+
+	url := "127.0.0.1"
+	testSubject := "test-jetstream-event-time"
+	testStream := "test-stream"
+
+	nc, err := natslib.Connect(url)
+	assert.NoError(t, err)
+	defer nc.Close()
+	js, err := nc.JetStream()
+	assert.NoError(t, err)
+	_, err = js.AddStream(&natslib.StreamConfig{Name: testStream, Subjects: []string{testSubject}})
+	assert.NoError(t, err)
+	_, err = js.Publish(testSubject, []byte("test"))
+	assert.NoError(t, err)
+	msg, err := js.GetLastMsg(testStream, testSubject)
+	assert.NoError(t, err)
+
+	// Make sure the read time differs from the publish time.
+	time.Sleep(10 * time.Millisecond)
+	ns, err := New(&config.Config{
+		URL:     url,
+		Subject: testSubject,
+		JetStream: &config.JetStream{
+			Stream:   testStream,
+			Consumer: "test-consumer",
+		},
+		EventTime: &config.EventTime{
+			Source: config.EventTimeFromPublishTime,
+		},
+	})
+	assert.NoError(t, err)
+	defer ns.Close()
+
+	messageCh := make(chan sourcesdk.Message, 10)
+	ns.Read(ctx, TestReadRequest{count: 1, timeout: 5 * time.Second}, messageCh)
+	assert.Equal(t, 1, len(messageCh))
+	assert.True(t, msg.Time.Equal((<-messageCh).EventTime()))
+}
+
+// Test_JetStreamClose tests that the JetStream messages which are not read before the source is closed
+// are redelivered right away
+func Test_JetStreamClose(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	server := RunJetStreamServer(t)
+	defer server.Shutdown()
+
+	url := "127.0.0.1"
+	testSubject := "test-jetstream-close"
+	testStream := "test-stream"
+
+	nc, err := natslib.Connect(url)
+	assert.NoError(t, err)
+	defer nc.Close()
+	js, err := nc.JetStream()
+	assert.NoError(t, err)
+	_, err = js.AddStream(&natslib.StreamConfig{Name: testStream, Subjects: []string{testSubject}})
+	assert.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = js.Publish(testSubject, []byte(fmt.Sprintf("%d", i)))
+		assert.NoError(t, err)
+	}
+
+	config := &config.Config{
+		URL:     url,
+		Subject: testSubject,
+		JetStream: &config.JetStream{
+			Stream:   testStream,
+			Consumer: "test-consumer",
+		},
+	}
+	ns, err := New(config)
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return len(ns.messages) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.NoError(t, ns.Close())
+
+	// The messages are redelivered without waiting for the default AckWait of 30s.
+	ns, err = New(config)
+	assert.NoError(t, err)
+	defer ns.Close()
+	messageCh := make(chan sourcesdk.Message, 10)
+	ns.Read(ctx, TestReadRequest{count: 3, timeout: 5 * time.Second}, messageCh)
+	assert.Equal(t, 3, len(messageCh))
+}
+
+// Test_JetStreamPending tests the pending count reported for a JetStream consumer
+func Test_JetStreamPending(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	server := RunJetStreamServer(t)
+	defer server.Shutdown()
+
+	url := "127.0.0.1"
+	testSubject := "test-jetstream-pending"
+	testStream := "test-stream"
+
+	nc, err := natslib.Connect(url)
+	assert.NoError(t, err)
+	defer nc.Close()
+	js, err := nc.JetStream()
+	assert.NoError(t, err)
+	_, err = js.AddStream(&natslib.StreamConfig{Name: testStream, Subjects: []string{testSubject}})
+	assert.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		_, err = js.Publish(testSubject, []byte(fmt.Sprintf("%d", i)))
+		assert.NoError(t, err)
+	}
+
+	ns, err := New(&config.Config{
+		URL:     url,
+		Subject: testSubject,
+		JetStream: &config.JetStream{
+			Stream:   testStream,
+			Consumer: "test-consumer",
+		},
+	})
+	assert.NoError(t, err)
+	defer ns.Close()
+	assert.Eventually(t, func() bool {
+		return ns.Pending(ctx) == 5
+	}, 5*time.Second, 10*time.Millisecond)
+
+	messageCh := make(chan sourcesdk.Message, 10)
+	ns.Read(ctx, TestReadRequest{count: 2, timeout: time.Second}, messageCh)
+	assert.Equal(t, 2, len(messageCh))
+	assert.Equal(t, int64(3), ns.Pending(ctx))
+}
+
+// Test_JetStreamRedelivery tests that the JetStream messages which are not acknowledged get redelivered.
+func Test_JetStreamRedelivery(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	server := RunJetStreamServer(t)
+	defer server.Shutdown()
+
+	url := "127.0.0.1"
+	testSubject := "test-redelivery"
+	testStream := "test-stream"
+
+	nc, err := natslib.Connect(url)
+	assert.NoError(t, err)
+	defer nc.Close()
+	js, err := nc.JetStream()
+	assert.NoError(t, err)
+	_, err = js.AddStream(&natslib.StreamConfig{Name: testStream, Subjects: []string{testSubject}})
+	assert.NoError(t, err)
+
+	ns, err := New(&config.Config{
+		URL:     url,
+		Subject: testSubject,
+		JetStream: &config.JetStream{
+			Stream:   testStream,
+			Consumer: "test-consumer",
+			AckWait:  &config.Duration{Duration: 500 * time.Millisecond},
+		},
+	})
+	assert.NoError(t, err)
+	defer ns.Close()
+
+	for i := 0; i < 2; i++ {
+		_, err = js.Publish(testSubject, []byte(fmt.Sprintf("%d", i)))
+		assert.NoError(t, err)
+	}
+	messageCh := make(chan sourcesdk.Message, 10)
+	ns.Read(ctx, TestReadRequest{count: 2, timeout: 5 * time.Second}, messageCh)
+	assert.Equal(t, 2, len(messageCh))
+
+	// Only acknowledge the first message, the second one is redelivered after AckWait.
+	first := <-messageCh
+	second := <-messageCh
+	ns.Ack(ctx, TestAckRequest{offsets: []sourcesdk.Offset{first.Offset()}})
+
+	ns.Read(ctx, TestReadRequest{count: 1, timeout: 5 * time.Second}, messageCh)
+	assert.Equal(t, 1, len(messageCh))
+	redelivered := <-messageCh
+	assert.Equal(t, second.Value(), redelivered.Value())
+	assert.NotEqual(t, second.Offset().Value(), redelivered.Offset().Value())
+	ns.Ack(ctx, TestAckRequest{offsets: []sourcesdk.Offset{redelivered.Offset()}})
+
+	// Nothing is left to be delivered once everything is acknowledged.
+	ns.Read(ctx, TestReadRequest{count: 1, timeout: time.Second}, messageCh)
+	assert.Equal(t, 0, len(messageCh))
+}
+
+// Test_JetStreamRedeliveryPolicy tests that the JetStream messages which are not acknowledged within the ack deadline
+// are redelivered after their backoff delay, and terminated after the maximum number of deliveries.
+func Test_JetStreamRedeliveryPolicy(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	server := RunJetStreamServer(t)
+	defer server.Shutdown()
+
+	url := "127.0.0.1"
+	testSubject := "test-redelivery-policy"
+	testStream := "test-stream"
+
+	nc, err := natslib.Connect(url)
+	assert.NoError(t, err)
+	defer nc.Close()
+	js, err := nc.JetStream()
+	assert.NoError(t, err)
+	_, err = js.AddStream(&natslib.StreamConfig{Name: testStream, Subjects: []string{testSubject}})
+	assert.NoError(t, err)
+
+	backOff := 300 * time.Millisecond
+	ns, err := New(&config.Config{
+		URL:     url,
+		Subject: testSubject,
+		JetStream: &config.JetStream{
+			Stream:      testStream,
+			Consumer:    "test-consumer",
+			MaxDeliver:  3,
+			BackOff:     []config.Duration{{Duration: backOff}},
+			AckDeadline: &config.Duration{Duration: 100 * time.Millisecond},
+		},
+	})
+	assert.NoError(t, err)
+	defer ns.Close()
+
+	info, err := js.ConsumerInfo(testStream, "test-consumer")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, info.Config.MaxDeliver)
+	assert.Equal(t, []time.Duration{backOff}, info.Config.BackOff)
+
+	_, err = js.Publish(testSubject, []byte("0"))
+	assert.NoError(t, err)
+
+	// The message is never acknowledged, it is delivered MaxDeliver times, each after the backoff delay.
+	var readAt []time.Time
+	for i := 0; i < 3; i++ {
+		messageCh := make(chan sourcesdk.Message, 10)
+		ns.Read(ctx, TestReadRequest{count: 1, timeout: 5 * time.Second}, messageCh)
+		if !assert.Equal(t, 1, len(messageCh)) {
+			return
+		}
+		assert.Equal(t, []byte("0"), (<-messageCh).Value())
+		readAt = append(readAt, time.Now())
+	}
+	for i := 1; i < len(readAt); i++ {
+		assert.GreaterOrEqual(t, readAt[i].Sub(readAt[i-1]), backOff)
+	}
+
+	// The last delivery is terminated once the ack deadline elapses.
+	assert.Eventually(t, func() bool {
+		info, err := js.ConsumerInfo(testStream, "test-consumer")
+		return err == nil && info.NumAckPending == 0
+	}, 5*time.Second, 50*time.Millisecond)
+	messageCh := make(chan sourcesdk.Message, 10)
+	ns.Read(ctx, TestReadRequest{count: 1, timeout: time.Second}, messageCh)
+	assert.Equal(t, 0, len(messageCh))
+}
+
+// Test_RedeliveryPolicy tests the backoff delay and the termination of the messages by number of deliveries.
+func Test_RedeliveryPolicy(t *testing.T) {
+	p := redeliveryPolicy{maxDeliver: 4, backOff: []time.Duration{time.Second, time.Minute}}
+	assert.Equal(t, time.Second, p.nakDelay(1))
+	assert.Equal(t, time.Minute, p.nakDelay(2))
+	assert.Equal(t, time.Minute, p.nakDelay(3))
+	assert.False(t, p.exhausted(3))
+	assert.True(t, p.exhausted(4))
+
+	p = redeliveryPolicy{}
+	assert.Equal(t, time.Duration(0), p.nakDelay(1))
+	assert.False(t, p.exhausted(100))
+}
+
+// Test_DeadLetter tests that the messages whose event time cannot be extracted are republished to the dead-letter subject
+func Test_DeadLetter(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	server := RunNatsServer(t)
+	defer server.Shutdown()
+
+	url := "127.0.0.1"
+	testSubject := "test-dead-letter"
+	nc, err := natslib.Connect(url)
+	assert.NoError(t, err)
+	defer nc.Close()
+	deadLetters, err := nc.SubscribeSync("test-dead-letters")
+	assert.NoError(t, err)
+
+	m := metrics.New()
+	ns, err := New(&config.Config{
+		URL:     url,
+		Subject: testSubject,
+		EventTime: &config.EventTime{
+			Source:   config.EventTimeFromHeader,
+			Header:   "Event-Time",
+			Format:   config.EventTimeFormatEpochMillis,
+			Fallback: config.EventTimeFallbackDrop,
+		},
+		DeadLetter: &config.DeadLetter{
+			Subject: "test-dead-letters",
+		},
+	}, WithMetrics(m))
+	assert.NoError(t, err)
+	defer ns.Close()
+
+	msg := natslib.NewMsg(testSubject)
+	msg.Data = []byte("no event time")
+	msg.Header.Set("Trace-Id", "1234")
+	assert.NoError(t, nc.PublishMsg(msg))
+
+	deadLetter, err := deadLetters.NextMsg(5 * time.Second)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []byte("no event time"), deadLetter.Data)
+	assert.Equal(t, "1234", deadLetter.Header.Get("Trace-Id"))
+	assert.Equal(t, testSubject, deadLetter.Header.Get(deadLetterSubjectHeader))
+	assert.Contains(t, deadLetter.Header.Get(deadLetterReasonHeader), "failed to extract event time")
+	assert.Empty(t, deadLetter.Header.Get(deadLetterSequenceHeader))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.MessagesDeadLettered.WithLabelValues(testSubject)))
+
+	messageCh := make(chan sourcesdk.Message, 10)
+	ns.Read(ctx, TestReadRequest{count: 1, timeout: 200 * time.Millisecond}, messageCh)
+	assert.Equal(t, 0, len(messageCh))
+}
+
+// Test_JetStreamDeadLetter tests that the JetStream messages exceeding the maximum number of deliveries are republished
+// to the dead-letter stream once, whether the source or the server stops redelivering them
+func Test_JetStreamDeadLetter(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	server := RunJetStreamServer(t)
+	defer server.Shutdown()
+
+	url := "127.0.0.1"
+	testStream := "test-stream"
+	nc, err := natslib.Connect(url)
+	assert.NoError(t, err)
+	defer nc.Close()
+	js, err := nc.JetStream()
+	assert.NoError(t, err)
+	_, err = js.AddStream(&natslib.StreamConfig{Name: testStream, Subjects: []string{"test-dead-letter.>"}})
+	assert.NoError(t, err)
+	_, err = js.AddStream(&natslib.StreamConfig{Name: "test-dead-letters", Subjects: []string{"test-dead-letters.>"}})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		jetStream *config.JetStream
+		// sources is the number of sources sharing the consumer, defaults to 1.
+		sources int
+	}{
+		{
+			name: "ack deadline",
+			jetStream: &config.JetStream{
+				MaxDeliver:  2,
+				AckDeadline: &config.Duration{Duration: 100 * time.Millisecond},
+			},
+		},
+		{
+			name: "max deliveries advisory",
+			jetStream: &config.JetStream{
+				MaxDeliver: 2,
+				AckWait:    &config.Duration{Duration: 200 * time.Millisecond},
+			},
+		},
+		{
+			name: "max deliveries advisory with two sources",
+			jetStream: &config.JetStream{
+				MaxDeliver: 2,
+				AckWait:    &config.Duration{Duration: 200 * time.Millisecond},
+			},
+			sources: 2,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testSubject := fmt.Sprintf("test-dead-letter.%d", i)
+			deadLetterSubject := fmt.Sprintf("test-dead-letters.%d", i)
+			tt.jetStream.Stream = testStream
+			tt.jetStream.Consumer = fmt.Sprintf("test-consumer-%d", i)
+			tt.jetStream.FilterSubject = testSubject
+			var sources []*natsSource
+			for j := 0; j < tt.sources || j == 0; j++ {
+				ns, err := New(&config.Config{
+					URL:       url,
+					JetStream: tt.jetStream,
+					DeadLetter: &config.DeadLetter{
+						Subject:   deadLetterSubject,
+						JetStream: true,
+					},
+				})
+				assert.NoError(t, err)
+				defer ns.Close()
+				sources = append(sources, ns)
+			}
+
+			ack, err := js.Publish(testSubject, []byte("poison"))
+			assert.NoError(t, err)
+
+			// The message is never acknowledged.
+			messageCh := make(chan sourcesdk.Message, 10)
+			for j := 0; len(messageCh) < 2 && ctx.Err() == nil; j++ {
+				sources[j%len(sources)].Read(ctx, TestReadRequest{count: 1, timeout: time.Second}, messageCh)
+			}
+			assert.Equal(t, 2, len(messageCh))
+
+			deadLetters, err := js.SubscribeSync(deadLetterSubject, natslib.DeliverAll())
+			assert.NoError(t, err)
+			defer func() { _ = deadLetters.Unsubscribe() }()
+			deadLetter, err := deadLetters.NextMsg(5 * time.Second)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, []byte("poison"), deadLetter.Data)
+			assert.Equal(t, testSubject, deadLetter.Header.Get(deadLetterSubjectHeader))
+			assert.Equal(t, testStream, deadLetter.Header.Get(deadLetterStreamHeader))
+			assert.Equal(t, strconv.FormatUint(ack.Sequence, 10), deadLetter.Header.Get(deadLetterSequenceHeader))
+			assert.Equal(t, "2", deadLetter.Header.Get(deadLetterDeliveriesHeader))
+			assert.Equal(t, maxDeliveriesReason, deadLetter.Header.Get(deadLetterReasonHeader))
+
+			// The message is dead-lettered only once.
+			_, err = deadLetters.NextMsg(500 * time.Millisecond)
+			assert.ErrorIs(t, err, natslib.ErrTimeout)
+		})
+	}
+}
+
+// Test_JetStreamProvision tests that the stream and the consumer are created, updated when the changes are compatible,
+// and left as is in dry-run mode or when the changes are incompatible
+func Test_JetStreamProvision(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	server := RunJetStreamServer(t)
+	defer server.Shutdown()
+
+	url := "127.0.0.1"
+	testStream := "test-stream-provision"
+	testConsumer := "test-consumer"
+	nc, err := natslib.Connect(url)
+	assert.NoError(t, err)
+	defer nc.Close()
+	js, err := nc.JetStream()
+	assert.NoError(t, err)
+
+	newConfig := func(maxAge time.Duration, maxAckPending int) *config.Config {
+		return &config.Config{
+			URL: url,
+			JetStream: &config.JetStream{
+				Stream:        testStream,
+				Consumer:      testConsumer,
+				FilterSubject: "test-provision.a",
+				MaxDeliver:    3,
+			},
+			Provision: &config.Provision{
+				Stream: &config.StreamProvision{
+					Subjects:  []string{"test-provision.>"},
+					Retention: config.RetentionLimits,
+					MaxAge:    &config.Duration{Duration: maxAge},
+				},
+				Consumer: &config.ConsumerProvision{
+					MaxAckPending: maxAckPending,
+				},
+			},
+		}
+	}
+	assertProvisioned := func(maxAge time.Duration, maxAckPending, maxDeliver int) {
+		stream, err := js.StreamInfo(testStream)
+		if assert.NoError(t, err) {
+			assert.Equal(t, []string{"test-provision.>"}, stream.Config.Subjects)
+			assert.Equal(t, natslib.LimitsPolicy, stream.Config.Retention)
+			assert.Equal(t, maxAge, stream.Config.MaxAge)
+		}
+		consumer, err := js.ConsumerInfo(testStream, testConsumer)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "test-provision.a", consumer.Config.FilterSubject)
+			assert.Equal(t, natslib.AckExplicitPolicy, consumer.Config.AckPolicy)
+			assert.Equal(t, maxAckPending, consumer.Config.MaxAckPending)
+			assert.Equal(t, maxDeliver, consumer.Config.MaxDeliver)
+		}
+	}
+
+	t.Run("create", func(t *testing.T) {
+		ns, err := New(newConfig(time.Hour, 100))
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer ns.Close()
+		assertProvisioned(time.Hour, 100, 3)
+
+		_, err = js.Publish("test-provision.a", []byte("provisioned"))
+		assert.NoError(t, err)
+		messageCh := make(chan sourcesdk.Message, 10)
+		ns.Read(ctx, TestReadRequest{count: 1, timeout: 5 * time.Second}, messageCh)
+		assert.Equal(t, 1, len(messageCh))
+		ackAll(ns, messageCh)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		c := newConfig(2*time.Hour, 200)
+		c.JetStream.MaxDeliver = 5
+		ns, err := New(c)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer ns.Close()
+		assertProvisioned(2*time.Hour, 200, 5)
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		// The differences, even those which cannot be updated, don't prevent binding to the consumer.
+		c := newConfig(3*time.Hour, 300)
+		c.JetStream.MaxDeliver = 7
+		c.Provision.DryRun = true
+		c.Provision.Stream.Retention = config.RetentionWorkQueue
+		ns, err := New(c)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer ns.Close()
+		assertProvisioned(2*time.Hour, 200, 5)
+
+		_, err = js.Publish("test-provision.a", []byte("dry run"))
+		assert.NoError(t, err)
+		messageCh := make(chan sourcesdk.Message, 10)
+		ns.Read(ctx, TestReadRequest{count: 1, timeout: 5 * time.Second}, messageCh)
+		assert.Equal(t, 1, len(messageCh))
+		ackAll(ns, messageCh)
+	})
+
+	t.Run("dry run without consumer", func(t *testing.T) {
+		c := newConfig(2*time.Hour, 200)
+		c.JetStream.Consumer = "test-consumer-missing"
+		c.Provision.DryRun = true
+		_, err := New(c)
+		assert.ErrorContains(t, err, "consumer test-consumer-missing must exist to be bound in dry-run mode")
+		_, err = js.ConsumerInfo(testStream, "test-consumer-missing")
+		assert.ErrorIs(t, err, natslib.ErrConsumerNotFound)
+	})
+
+	t.Run("incompatible", func(t *testing.T) {
+		c := newConfig(3*time.Hour, 300)
+		c.JetStream.DeliverPolicy = config.DeliverNew
+		_, err := New(c)
+		assert.ErrorContains(t, err, "JetStream can't be updated to match the config, consumer test-consumer: deliverPolicy is all instead of new")
+		// Nothing is updated, even the compatible changes.
+		assertProvisioned(2*time.Hour, 200, 5)
+	})
+}
+
+// Test_KeyValue tests that the puts and deletes of the watched keys of a KV bucket are read, after the initial snapshot
+// when it is enabled, without being dropped when the buffer is full, and wrapped in an envelope when it is enabled
+func Test_KeyValue(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	server := RunJetStreamServer(t)
+	defer server.Shutdown()
+
+	url := "127.0.0.1"
+	nc, err := natslib.Connect(url)
+	assert.NoError(t, err)
+	defer nc.Close()
+	js, err := nc.JetStream()
+	assert.NoError(t, err)
+	kv, err := js.CreateKeyValue(&natslib.KeyValueConfig{Bucket: "test-bucket"})
+	assert.NoError(t, err)
+
+	put := func(key, value string) uint64 {
+		revision, err := kv.PutString(key, value)
+		assert.NoError(t, err)
+		return revision
+	}
+	first := put("orders.1", "a")
+	put("orders.2", "b")
+	assert.NoError(t, kv.Delete("orders.2"))
+	put("users.1", "x")
+
+	t.Run("initial snapshot", func(t *testing.T) {
+		ns, err := New(&config.Config{
+			URL: url,
+			KeyValue: &config.KeyValue{
+				Bucket:          "test-bucket",
+				Keys:            "orders.>",
+				InitialSnapshot: true,
+			},
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer ns.Close()
+
+		next := func() *Message {
+			select {
+			case m := <-ns.messages:
+				return m
+			case <-time.After(5 * time.Second):
+				assert.Fail(t, "timed out waiting for the message")
+				return &Message{}
+			}
+		}
+		// The deleted key is not part of the snapshot.
+		m := next()
+		entry, err := kv.GetRevision("orders.1", first)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"orders.1"}, m.keys)
+		assert.Equal(t, []byte("a"), m.payload)
+		assert.Equal(t, strconv.FormatUint(first, 10), m.readOffset)
+		assert.True(t, entry.Created().Equal(m.eventTime))
+
+		put("users.2", "y")
+		revision := put("orders.3", "c")
+		m = next()
+		assert.Equal(t, []string{"orders.3"}, m.keys)
+		assert.Equal(t, []byte("c"), m.payload)
+		assert.Equal(t, strconv.FormatUint(revision, 10), m.readOffset)
+
+		assert.NoError(t, kv.Delete("orders.1"))
+		m = next()
+		assert.Equal(t, []string{"orders.1"}, m.keys)
+		assert.Empty(t, m.payload)
+	})
+
+	t.Run("updates only", func(t *testing.T) {
+		ns, err := New(&config.Config{
+			URL: url,
+			KeyValue: &config.KeyValue{
+				Bucket: "test-bucket",
+			},
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer ns.Close()
+
+		revision := put("users.3", "z")
+		messageCh := make(chan sourcesdk.Message, 10)
+		ns.Read(ctx, TestReadRequest{count: 10, timeout: time.Second}, messageCh)
+		if !assert.Equal(t, 1, len(messageCh)) {
+			return
+		}
+		m := <-messageCh
+		assert.Equal(t, []string{"users.3"}, m.Keys())
+		assert.Equal(t, []byte("z"), m.Value())
+		assert.Equal(t, []byte(strconv.FormatUint(revision, 10)), m.Offset().Value())
+		ackAll(ns, messageCh)
+	})
+
+	t.Run("full buffer", func(t *testing.T) {
+		ns, err := New(&config.Config{
+			URL: url,
+			KeyValue: &config.KeyValue{
+				Bucket: "test-bucket",
+				Keys:   "items.>",
+			},
+			Buffer: &config.Buffer{
+				Size:           1,
+				OverflowPolicy: config.OverflowDropNewest,
+			},
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer ns.Close()
+
+		// The updates are not dropped by the overflow policy.
+		for i := 0; i < 3; i++ {
+			put(fmt.Sprintf("items.%d", i), "v")
+		}
+		messageCh := make(chan sourcesdk.Message, 10)
+		for len(messageCh) < 3 && ctx.Err() == nil {
+			ns.Read(ctx, TestReadRequest{count: 3, timeout: time.Second}, messageCh)
+		}
+		assert.Equal(t, 3, len(messageCh))
+		ackAll(ns, messageCh)
+	})
+
+	t.Run("envelope", func(t *testing.T) {
+		ns, err := New(&config.Config{
+			URL: url,
+			KeyValue: &config.KeyValue{
+				Bucket:   "test-bucket",
+				Keys:     "users.>",
+				Envelope: true,
+			},
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer ns.Close()
+
+		revision := put("users.4", "w")
+		assert.NoError(t, kv.Delete("users.4"))
+		messageCh := make(chan sourcesdk.Message, 10)
+		for len(messageCh) < 2 && ctx.Err() == nil {
+			ns.Read(ctx, TestReadRequest{count: 2, timeout: time.Second}, messageCh)
+		}
+		if !assert.Equal(t, 2, len(messageCh)) {
+			return
+		}
+		m := <-messageCh
+		assert.JSONEq(t, fmt.Sprintf(`{"key":"users.4","operation":"PUT","revision":%d,"value":"dw=="}`, revision), string(m.Value()))
+		m = <-messageCh
+		assert.JSONEq(t, fmt.Sprintf(`{"key":"users.4","operation":"DEL","revision":%d}`, revision+1), string(m.Value()))
+		ackAll(ns, messageCh)
+	})
+}
+
+// Benchmark_Read measures the allocations of a message on its way from the subscription callback to Read.
+func Benchmark_Read(b *testing.B) {
+	payload := make([]byte, 64*1024)
+	ctx := context.Background()
+	readRequest := TestReadRequest{count: 1, timeout: time.Second}
+	ns := &natsSource{messages: make(chan *Message, 1), metrics: metrics.New()}
+	messageCh := make(chan sourcesdk.Message, 1)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m, _ := ns.newMessage(&natslib.Msg{Subject: "test", Data: payload}, "test", "offset")
+		ns.messages <- m
+		ns.Read(ctx, readRequest, messageCh)
+		<-messageCh
+	}
+}
 
 // RunNatsServer starts a nats server
 func RunNatsServer(t *testing.T) *server.Server {
@@ -150,3 +2017,12 @@ func RunNatsServer(t *testing.T) *server.Server {
 	opts := natstestserver.DefaultTestOptions
 	return natstestserver.RunServer(&opts)
 }
+
+// RunJetStreamServer starts a nats server with JetStream enabled
+func RunJetStreamServer(t *testing.T) *server.Server {
+	t.Helper()
+	opts := natstestserver.DefaultTestOptions
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	return natstestserver.RunServer(&opts)
+}
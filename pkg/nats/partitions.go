@@ -0,0 +1,65 @@
+package nats
+
+import (
+	"fmt"
+
+	sourcesdk "github.com/numaproj/numaflow-go/pkg/sourcer"
+
+	"github.com/numaproj-contrib/nats-source-go/pkg/config"
+)
+
+// partitionSubscription is a subscription of the source along with the partition its messages belong to.
+type partitionSubscription struct {
+	config.Subscription
+	partition int32
+	// consumer is the durable name of the JetStream consumer, it is empty for core NATS.
+	consumer string
+}
+
+// partitionIDs returns the partitions of the source, which is the default partition unless partitions are configured.
+func partitionIDs(c *config.Config) []int32 {
+	if c.Partitions == nil {
+		return sourcesdk.DefaultPartitions()
+	}
+	ids := make([]int32, c.Partitions.Count)
+	for i := range ids {
+		ids[i] = int32(i)
+	}
+	return ids
+}
+
+// partitionSubscriptions returns the subscriptions of the source.
+// With partitions, there is one subscription per partition, with its own durable consumer for JetStream.
+// Otherwise all the subscriptions belong to the default partition.
+func partitionSubscriptions(c *config.Config) []partitionSubscription {
+	var subs []partitionSubscription
+	if c.Partitions != nil {
+		for _, id := range partitionIDs(c) {
+			s := partitionSubscription{
+				Subscription: config.Subscription{Subject: c.Partitions.Subject(int(id)), Queue: c.Queue},
+				partition:    id,
+			}
+			if c.JetStream != nil {
+				s.consumer = fmt.Sprintf("%s-%d", c.JetStream.Consumer, id)
+			}
+			subs = append(subs, s)
+		}
+		return subs
+	}
+	partition := sourcesdk.DefaultPartitions()[0]
+	if c.JetStream != nil {
+		filterSubject := c.JetStream.FilterSubject
+		if filterSubject == "" {
+			filterSubject = c.Subject
+		}
+		return []partitionSubscription{{
+			Subscription: config.Subscription{Subject: filterSubject},
+			partition:    partition,
+			consumer:     c.JetStream.Consumer,
+		}}
+	}
+	for _, s := range c.GetSubscriptions() {
+		subs = append(subs, partitionSubscription{Subscription: s, partition: partition})
+	}
+	return subs
+}
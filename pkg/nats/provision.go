@@ -0,0 +1,309 @@
+package nats
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	natslib "github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+
+	"github.com/numaproj-contrib/nats-source-go/pkg/config"
+)
+
+var (
+	deliverPolicies = map[config.DeliverPolicy]natslib.DeliverPolicy{
+		"":                            natslib.DeliverAllPolicy,
+		config.DeliverAll:             natslib.DeliverAllPolicy,
+		config.DeliverLast:            natslib.DeliverLastPolicy,
+		config.DeliverNew:             natslib.DeliverNewPolicy,
+		config.DeliverByStartSequence: natslib.DeliverByStartSequencePolicy,
+		config.DeliverByStartTime:     natslib.DeliverByStartTimePolicy,
+		config.DeliverLastPerSubject:  natslib.DeliverLastPerSubjectPolicy,
+	}
+	retentionPolicies = map[config.RetentionPolicy]natslib.RetentionPolicy{
+		"":                        natslib.LimitsPolicy,
+		config.RetentionLimits:    natslib.LimitsPolicy,
+		config.RetentionInterest:  natslib.InterestPolicy,
+		config.RetentionWorkQueue: natslib.WorkQueuePolicy,
+	}
+	storageTypes = map[config.StorageType]natslib.StorageType{
+		"":                   natslib.FileStorage,
+		config.StorageFile:   natslib.FileStorage,
+		config.StorageMemory: natslib.MemoryStorage,
+	}
+)
+
+// settingDiff is a setting of a stream or a consumer whose current value differs from the config.
+type settingDiff struct {
+	name    string
+	current interface{}
+	desired interface{}
+	// immutable is true when the server cannot update the setting.
+	immutable bool
+}
+
+func (d settingDiff) String() string {
+	return fmt.Sprintf("%s is %v instead of %v", d.name, d.current, d.desired)
+}
+
+// settingDiffs collects the differences between the current settings of a stream or a consumer and the config.
+type settingDiffs []settingDiff
+
+// compare records a difference between the current and desired values of a setting, and returns whether they differ.
+func (d *settingDiffs) compare(name string, current, desired interface{}, immutable bool) bool {
+	if reflect.DeepEqual(current, desired) {
+		return false
+	}
+	*d = append(*d, settingDiff{name: name, current: current, desired: desired, immutable: immutable})
+	return true
+}
+
+// incompatible returns the differences which the server cannot update.
+func (d settingDiffs) incompatible() error {
+	var diffs []string
+	for _, diff := range d {
+		if diff.immutable {
+			diffs = append(diffs, diff.String())
+		}
+	}
+	if len(diffs) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(diffs, ", "))
+}
+
+// change is the creation or the update of a stream or a consumer, described by what.
+type change struct {
+	what string
+	// diffs are the differences with the existing stream or consumer, empty when it is created.
+	diffs settingDiffs
+	// apply creates or updates the stream or consumer, nil when it is up to date.
+	apply func() error
+}
+
+// provision creates the stream and the consumers of the subscriptions, or updates them to match the config.
+// Nothing is changed when some of the differences cannot be updated, or in dry-run mode where they are only logged.
+func (n *natsSource) provision(c *config.Config, subscriptions []partitionSubscription) error {
+	var changes []change
+	if c.Provision.Stream != nil {
+		ch, err := n.streamChange(c.JetStream.Stream, c.Provision.Stream)
+		if err != nil {
+			return err
+		}
+		changes = append(changes, ch)
+	}
+	for _, s := range subscriptions {
+		cfg, err := n.consumerConfig(c, s)
+		if err != nil {
+			return err
+		}
+		ch, err := n.consumerChange(c.JetStream.Stream, cfg, c.JetStream.DeliverPolicy != "")
+		if err != nil {
+			return err
+		}
+		changes = append(changes, ch)
+	}
+
+	log := n.logger.With(zap.Bool("dryRun", c.Provision.DryRun))
+	var incompatible []string
+	for _, ch := range changes {
+		switch {
+		case ch.apply == nil:
+			log.Info(fmt.Sprintf("JetStream %s is up to date", ch.what))
+		case len(ch.diffs) == 0:
+			log.Info(fmt.Sprintf("JetStream %s does not exist", ch.what))
+		}
+		for _, d := range ch.diffs {
+			log.Info(fmt.Sprintf("JetStream %s differs from the config", ch.what), zap.String("setting", d.name),
+				zap.Any("current", d.current), zap.Any("desired", d.desired), zap.Bool("updatable", !d.immutable))
+		}
+		if err := ch.diffs.incompatible(); err != nil {
+			incompatible = append(incompatible, fmt.Sprintf("%s: %s", ch.what, err))
+		}
+	}
+	if c.Provision.DryRun {
+		return nil
+	}
+	if len(incompatible) > 0 {
+		return fmt.Errorf("JetStream can't be updated to match the config, %s", strings.Join(incompatible, "; "))
+	}
+	for _, ch := range changes {
+		if ch.apply == nil {
+			continue
+		}
+		log.Info(fmt.Sprintf("Provisioning JetStream %s", ch.what))
+		if err := ch.apply(); err != nil {
+			return fmt.Errorf("failed to provision JetStream %s, %w", ch.what, err)
+		}
+	}
+	return nil
+}
+
+// streamChange returns the creation of the stream, or the update of the settings specified by the config.
+func (n *natsSource) streamChange(name string, p *config.StreamProvision) (change, error) {
+	desired := natslib.StreamConfig{
+		Name:      name,
+		Subjects:  p.Subjects,
+		Retention: retentionPolicies[p.Retention],
+		Storage:   storageTypes[p.Storage],
+		Replicas:  p.Replicas,
+	}
+	if p.MaxAge != nil {
+		desired.MaxAge = p.MaxAge.Duration
+	}
+	ch := change{what: "stream " + name}
+
+	info, err := n.js.StreamInfo(name)
+	if errors.Is(err, natslib.ErrStreamNotFound) {
+		ch.apply = func() error {
+			_, err := n.js.AddStream(&desired)
+			return err
+		}
+		return ch, nil
+	}
+	if err != nil {
+		return ch, fmt.Errorf("failed to get JetStream %s, %w", ch.what, err)
+	}
+
+	current := info.Config
+	updated := current
+	if ch.diffs.compare("subjects", sortedStrings(current.Subjects), sortedStrings(desired.Subjects), false) {
+		updated.Subjects = desired.Subjects
+	}
+	if p.Retention != "" {
+		ch.diffs.compare("retention", current.Retention, desired.Retention, true)
+	}
+	if p.Storage != "" {
+		ch.diffs.compare("storage", current.Storage, desired.Storage, true)
+	}
+	if p.Replicas > 0 && ch.diffs.compare("replicas", current.Replicas, desired.Replicas, false) {
+		updated.Replicas = desired.Replicas
+	}
+	if p.MaxAge != nil && ch.diffs.compare("maxAge", current.MaxAge, desired.MaxAge, false) {
+		updated.MaxAge = desired.MaxAge
+	}
+	if len(ch.diffs) > 0 {
+		ch.apply = func() error {
+			_, err := n.js.UpdateStream(&updated)
+			return err
+		}
+	}
+	return ch, nil
+}
+
+// consumerConfig returns the config of the consumer of a subscription, as created by the source.
+func (n *natsSource) consumerConfig(c *config.Config, s partitionSubscription) (*natslib.ConsumerConfig, error) {
+	deliverPolicy, ok := deliverPolicies[c.JetStream.DeliverPolicy]
+	if !ok {
+		return nil, fmt.Errorf("unsupported JetStream deliver policy %q", c.JetStream.DeliverPolicy)
+	}
+	cfg := &natslib.ConsumerConfig{
+		Durable:       s.consumer,
+		FilterSubject: s.Subject,
+		DeliverPolicy: deliverPolicy,
+		AckPolicy:     natslib.AckExplicitPolicy,
+		MaxDeliver:    n.redelivery.maxDeliver,
+		BackOff:       n.redelivery.backOff,
+	}
+	if c.JetStream.AckWait != nil {
+		cfg.AckWait = c.JetStream.AckWait.Duration
+	}
+	switch deliverPolicy {
+	case natslib.DeliverByStartSequencePolicy:
+		cfg.OptStartSeq = c.JetStream.StartSequence
+	case natslib.DeliverByStartTimePolicy:
+		if c.JetStream.StartTime == nil {
+			return nil, fmt.Errorf("a start time is required with JetStream deliver policy %q", c.JetStream.DeliverPolicy)
+		}
+		startTime := c.JetStream.StartTime.Time
+		cfg.OptStartTime = &startTime
+	}
+	if p := c.Provision.Consumer; p != nil {
+		cfg.Replicas = p.Replicas
+		cfg.MaxAckPending = p.MaxAckPending
+	}
+	return cfg, nil
+}
+
+// consumerChange returns the creation of the consumer, or the update of the settings specified by the config.
+// The deliver policy of an existing consumer is only compared when withDeliverPolicy is true,
+// as the source binds to it whatever its policy otherwise.
+func (n *natsSource) consumerChange(stream string, desired *natslib.ConsumerConfig, withDeliverPolicy bool) (change, error) {
+	ch := change{what: "consumer " + desired.Durable}
+
+	info, err := n.js.ConsumerInfo(stream, desired.Durable)
+	// The stream may not be created yet.
+	if errors.Is(err, natslib.ErrConsumerNotFound) || errors.Is(err, natslib.ErrStreamNotFound) {
+		ch.apply = func() error {
+			_, err := n.js.AddConsumer(stream, desired)
+			return err
+		}
+		return ch, nil
+	}
+	if err != nil {
+		return ch, fmt.Errorf("failed to get JetStream %s, %w", ch.what, err)
+	}
+
+	current := info.Config
+	updated := current
+	if withDeliverPolicy {
+		ch.diffs.compare("deliverPolicy", deliverPolicyName(current.DeliverPolicy), deliverPolicyName(desired.DeliverPolicy), true)
+		ch.diffs.compare("startSequence", current.OptStartSeq, desired.OptStartSeq, true)
+		ch.diffs.compare("startTime", utcTime(current.OptStartTime), utcTime(desired.OptStartTime), true)
+	}
+	ch.diffs.compare("ackPolicy", current.AckPolicy, desired.AckPolicy, true)
+	if ch.diffs.compare("filterSubject", current.FilterSubject, desired.FilterSubject, false) {
+		updated.FilterSubject = desired.FilterSubject
+	}
+	if desired.AckWait > 0 && ch.diffs.compare("ackWait", current.AckWait, desired.AckWait, false) {
+		updated.AckWait = desired.AckWait
+	}
+	if desired.MaxDeliver != 0 && ch.diffs.compare("maxDeliver", current.MaxDeliver, desired.MaxDeliver, false) {
+		updated.MaxDeliver = desired.MaxDeliver
+	}
+	if len(desired.BackOff) > 0 && ch.diffs.compare("backOff", current.BackOff, desired.BackOff, false) {
+		updated.BackOff = desired.BackOff
+	}
+	if desired.Replicas > 0 && ch.diffs.compare("replicas", current.Replicas, desired.Replicas, false) {
+		updated.Replicas = desired.Replicas
+	}
+	if desired.MaxAckPending != 0 && ch.diffs.compare("maxAckPending", current.MaxAckPending, desired.MaxAckPending, false) {
+		updated.MaxAckPending = desired.MaxAckPending
+	}
+	if len(ch.diffs) > 0 {
+		ch.apply = func() error {
+			_, err := n.js.UpdateConsumer(stream, &updated)
+			return err
+		}
+	}
+	return ch, nil
+}
+
+// deliverPolicyName returns the config value of a deliver policy, for the differences to be readable.
+func deliverPolicyName(p natslib.DeliverPolicy) string {
+	for name, policy := range deliverPolicies {
+		if name != "" && policy == p {
+			return string(name)
+		}
+	}
+	return fmt.Sprint(int(p))
+}
+
+// sortedStrings returns a sorted copy of values, to compare them regardless of their order.
+func sortedStrings(values []string) []string {
+	sorted := append([]string(nil), values...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// utcTime returns the time in UTC without its monotonic clock reading, to compare it regardless of its location.
+func utcTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Round(0)
+}
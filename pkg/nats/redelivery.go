@@ -0,0 +1,131 @@
+package nats
+
+import (
+	"context"
+	"time"
+
+	natslib "github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+
+	"github.com/numaproj-contrib/nats-source-go/pkg/config"
+)
+
+// maxExpiryInterval is the maximum interval between two checks of the in-flight messages against the ack deadline.
+const maxExpiryInterval = time.Second
+
+// inflightMessage is a JetStream message which has been read but not acknowledged yet.
+type inflightMessage struct {
+	msg *natslib.Msg
+	// subject is the subject of the subscription which received the message.
+	subject string
+	readAt  time.Time
+}
+
+// redeliveryPolicy decides what happens to the JetStream messages which Numaflow does not acknowledge in time.
+type redeliveryPolicy struct {
+	// ackDeadline is how long a message can stay in flight, 0 leaves the redelivery to the server after AckWait.
+	ackDeadline time.Duration
+	// maxDeliver is the number of deliveries after which a message is terminated, 0 or less means unlimited.
+	maxDeliver int
+	// backOff are the redelivery delays by delivery, the last one being used for the next deliveries.
+	backOff []time.Duration
+}
+
+func newRedeliveryPolicy(c *config.JetStream) redeliveryPolicy {
+	p := redeliveryPolicy{maxDeliver: c.MaxDeliver}
+	if c.AckDeadline != nil {
+		p.ackDeadline = c.AckDeadline.Duration
+	}
+	for _, d := range c.BackOff {
+		p.backOff = append(p.backOff, d.Duration)
+	}
+	return p
+}
+
+// subOpts returns the consumer options applying the policy on the server side.
+func (p redeliveryPolicy) subOpts() []natslib.SubOpt {
+	var opts []natslib.SubOpt
+	if p.maxDeliver != 0 {
+		opts = append(opts, natslib.MaxDeliver(p.maxDeliver))
+	}
+	if len(p.backOff) > 0 {
+		opts = append(opts, natslib.BackOff(p.backOff))
+	}
+	return opts
+}
+
+// nakDelay returns the delay before redelivering a message which has been delivered the given number of times.
+func (p redeliveryPolicy) nakDelay(delivered uint64) time.Duration {
+	if len(p.backOff) == 0 {
+		return 0
+	}
+	i := len(p.backOff) - 1
+	if delivered > 0 && delivered <= uint64(len(p.backOff)) {
+		i = int(delivered) - 1
+	}
+	return p.backOff[i]
+}
+
+// exhausted returns whether a message which has been delivered the given number of times must not be redelivered.
+func (p redeliveryPolicy) exhausted(delivered uint64) bool {
+	return p.maxDeliver > 0 && delivered >= uint64(p.maxDeliver)
+}
+
+// expireInflight periodically releases the in-flight messages which have not been acknowledged within the ack
+// deadline, until the context is cancelled.
+func (n *natsSource) expireInflight(ctx context.Context) {
+	defer n.wg.Done()
+	interval := n.redelivery.ackDeadline / 4
+	if interval > maxExpiryInterval {
+		interval = maxExpiryInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			n.expire(now)
+		}
+	}
+}
+
+// expire removes the in-flight messages read before now minus the ack deadline and redelivers them.
+func (n *natsSource) expire(now time.Time) {
+	var expired []inflightMessage
+	n.inflightMu.Lock()
+	for offset, m := range n.inflight {
+		if now.Sub(m.readAt) >= n.redelivery.ackDeadline {
+			expired = append(expired, m)
+			delete(n.inflight, offset)
+		}
+	}
+	n.inflightMu.Unlock()
+	for _, m := range expired {
+		n.redeliver(m.msg, m.subject)
+	}
+}
+
+// redeliver negatively acknowledges a message received by the subscription to the given subject with the backoff
+// delay of its delivery, or terminates it once it has been delivered the maximum number of times.
+func (n *natsSource) redeliver(msg *natslib.Msg, subject string) {
+	var delivered uint64
+	if meta, err := msg.Metadata(); err != nil {
+		n.logger.Error("Failed to get JetStream message metadata", zap.Error(err))
+	} else {
+		delivered = meta.NumDelivered
+	}
+	if n.redelivery.exhausted(delivered) {
+		n.logger.Warn("JetStream message not acknowledged after the maximum number of deliveries, terminating it",
+			zap.String("subject", msg.Subject), zap.Uint64("delivered", delivered))
+		n.terminate(msg, subject, maxDeliveriesReason)
+		return
+	}
+	delay := n.redelivery.nakDelay(delivered)
+	n.logger.Debug("JetStream message not acknowledged in time, redelivering it",
+		zap.String("subject", msg.Subject), zap.Uint64("delivered", delivered), zap.Duration("delay", delay))
+	if err := msg.NakWithDelay(delay); err != nil {
+		n.logger.Error("Failed to nak JetStream message", zap.Error(err))
+	}
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// GetJSONPathValue returns the value of the field at the dot separated path in a JSON document, e.g. "customer.id".
+// Numeric path elements index into arrays, e.g. "items.0.sku". String values are returned as is,
+// any other value is returned as its JSON representation.
+func GetJSONPathValue(data []byte, path string) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return "", fmt.Errorf("failed to decode JSON document, %w", err)
+	}
+	for _, p := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			child, ok := node[p]
+			if !ok {
+				return "", fmt.Errorf("field %q not found in path %s", p, path)
+			}
+			v = child
+		case []interface{}:
+			i, err := strconv.Atoi(p)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", fmt.Errorf("invalid array index %q in path %s", p, path)
+			}
+			v = node[i]
+		default:
+			return "", fmt.Errorf("field %q not found in path %s", p, path)
+		}
+	}
+	switch value := v.(type) {
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	case nil:
+		return "", fmt.Errorf("field at path %s is null", path)
+	default:
+		b, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+}
@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GetJSONPathValue(t *testing.T) {
+	data := []byte(`{"customer":{"id":"c-1","age":42,"vip":true,"tags":["a","b"]},"items":[{"sku":"s-1"},{"sku":"s-2"}],"note":null}`)
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{path: "customer.id", want: "c-1"},
+		{path: "customer.age", want: "42"},
+		{path: "customer.vip", want: "true"},
+		{path: "customer.tags", want: `["a","b"]`},
+		{path: "items.1.sku", want: "s-2"},
+		{path: "items.2.sku", wantErr: true},
+		{path: "customer.name", wantErr: true},
+		{path: "customer.id.value", wantErr: true},
+		{path: "note", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			v, err := GetJSONPathValue(data, tt.path)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, v)
+		})
+	}
+	_, err := GetJSONPathValue([]byte("not json"), "id")
+	assert.Error(t, err)
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// SecretWatcher watches mounted secret files and notifies their changes, e.g. when a secret is rotated.
+// The directories of the files are watched rather than the files themselves,
+// because Kubernetes updates a mounted secret by atomically swapping a symlink in its directory.
+type SecretWatcher struct {
+	watcher *fsnotify.Watcher
+	logger  *zap.Logger
+
+	mu sync.Mutex
+	// files holds the last known content of the watched files, keyed by path.
+	files    map[string][]byte
+	handlers map[string][]func([]byte)
+	dirs     map[string]bool
+
+	done chan struct{}
+}
+
+// NewSecretWatcher creates a new SecretWatcher and starts watching for changes.
+func NewSecretWatcher(logger *zap.Logger) (*SecretWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher, %w", err)
+	}
+	w := &SecretWatcher{
+		watcher:  watcher,
+		logger:   logger,
+		files:    make(map[string][]byte),
+		handlers: make(map[string][]func([]byte)),
+		dirs:     make(map[string]bool),
+		done:     make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+// Watch starts watching the file at the given path, onChange is called with the new content whenever it changes.
+func (w *SecretWatcher) Watch(path string, onChange func([]byte)) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read secret file %s, %w", path, err)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	dir := filepath.Dir(path)
+	if !w.dirs[dir] {
+		if err := w.watcher.Add(dir); err != nil {
+			return fmt.Errorf("failed to watch secret directory %s, %w", dir, err)
+		}
+		w.dirs[dir] = true
+	}
+	w.files[path] = data
+	w.handlers[path] = append(w.handlers[path], onChange)
+	return nil
+}
+
+// Close stops watching the files.
+func (w *SecretWatcher) Close() error {
+	err := w.watcher.Close()
+	<-w.done
+	return err
+}
+
+func (w *SecretWatcher) run() {
+	defer close(w.done)
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			w.refresh(filepath.Dir(event.Name))
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.logger.Error("Secret watcher error", zap.Error(err))
+		}
+	}
+}
+
+// refresh re-reads the watched files in the directory and notifies the ones which have changed.
+func (w *SecretWatcher) refresh(dir string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for path, content := range w.files {
+		if filepath.Dir(path) != dir {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			// The file might be in the middle of an update, it will be read again on the next event.
+			w.logger.Debug("Failed to read secret file", zap.String("path", path), zap.Error(err))
+			continue
+		}
+		if bytes.Equal(data, content) {
+			continue
+		}
+		w.logger.Info("Secret file changed", zap.String("path", path))
+		w.files[path] = data
+		for _, h := range w.handlers[path] {
+			h(data)
+		}
+	}
+}
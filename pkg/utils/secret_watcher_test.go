@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func Test_SecretWatcher(t *testing.T) {
+	secretPath := t.TempDir()
+	// Lay out the secret the same way Kubernetes mounts it, the key being a symlink to a versioned directory.
+	assert.NoError(t, os.MkdirAll(fmt.Sprintf("%s/v1", secretPath), 0750))
+	assert.NoError(t, os.WriteFile(fmt.Sprintf("%s/v1/test-key", secretPath), []byte("v1"), 0600))
+	assert.NoError(t, os.Symlink("v1", fmt.Sprintf("%s/..data", secretPath)))
+	assert.NoError(t, os.Symlink("..data/test-key", fmt.Sprintf("%s/test-key", secretPath)))
+
+	underTest, err := NewSecretWatcher(zap.NewNop())
+	assert.NoError(t, err)
+	defer underTest.Close()
+
+	var current atomic.Value
+	current.Store("v1")
+	err = underTest.Watch(fmt.Sprintf("%s/test-key", secretPath), func(data []byte) {
+		current.Store(string(data))
+	})
+	assert.NoError(t, err)
+
+	// Rotate the secret by swapping the symlink.
+	assert.NoError(t, os.MkdirAll(fmt.Sprintf("%s/v2", secretPath), 0750))
+	assert.NoError(t, os.WriteFile(fmt.Sprintf("%s/v2/test-key", secretPath), []byte("v2"), 0600))
+	assert.NoError(t, os.Symlink("v2", fmt.Sprintf("%s/..data_tmp", secretPath)))
+	assert.NoError(t, os.Rename(fmt.Sprintf("%s/..data_tmp", secretPath), fmt.Sprintf("%s/..data", secretPath)))
+
+	assert.Eventually(t, func() bool {
+		return current.Load() == "v2"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Watching a missing file fails.
+	err = underTest.Watch(fmt.Sprintf("%s/missing-key", secretPath), func([]byte) {})
+	assert.Error(t, err)
+}
@@ -5,6 +5,9 @@ import (
 	"crypto/x509"
 	"fmt"
 	"os"
+	"sync/atomic"
+
+	"go.uber.org/zap"
 
 	"github.com/numaproj-contrib/nats-source-go/pkg/config"
 )
@@ -67,3 +70,44 @@ func GetTLSConfig(config *config.TLS, reader VolumeReader) (*tls.Config, error)
 	}
 	return c, nil
 }
+
+// WatchClientCertificate makes the client certificate of a tls.Config returned by GetTLSConfig reloadable.
+// The certificate is served through GetClientCertificate and reloaded whenever the cert or the key secret changes,
+// so that the next TLS handshake uses the rotated certificate.
+func WatchClientCertificate(c *tls.Config, config *config.TLS, reader VolumeReader, watcher *SecretWatcher) error {
+	if c == nil || len(c.Certificates) == 0 {
+		return nil
+	}
+	certPath, err := reader.GetSecretVolumePath(config.CertSecret)
+	if err != nil {
+		return err
+	}
+	keyPath, err := reader.GetSecretVolumePath(config.KeySecret)
+	if err != nil {
+		return err
+	}
+
+	var current atomic.Pointer[tls.Certificate]
+	current.Store(&c.Certificates[0])
+	reload := func([]byte) {
+		clientCert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			// The cert and the key are not necessarily updated at the same time, keep the current pair until both are.
+			watcher.logger.Warn("Failed to reload client cert key pair", zap.Error(err))
+			return
+		}
+		current.Store(&clientCert)
+		watcher.logger.Info("Reloaded client cert key pair")
+	}
+	if err := watcher.Watch(certPath, reload); err != nil {
+		return err
+	}
+	if err := watcher.Watch(keyPath, reload); err != nil {
+		return err
+	}
+	c.Certificates = nil
+	c.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		return current.Load(), nil
+	}
+	return nil
+}